@@ -1,16 +1,26 @@
 package main
 
 import (
+	"cmp"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/akshaykhairmode/wscli/pkg/codec"
 	"github.com/akshaykhairmode/wscli/pkg/config"
 	"github.com/akshaykhairmode/wscli/pkg/global"
 	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/akshaykhairmode/wscli/pkg/mock"
 	"github.com/akshaykhairmode/wscli/pkg/perf"
 	"github.com/akshaykhairmode/wscli/pkg/processer"
+	"github.com/akshaykhairmode/wscli/pkg/protocol"
+	"github.com/akshaykhairmode/wscli/pkg/record"
+	"github.com/akshaykhairmode/wscli/pkg/relay"
+	"github.com/akshaykhairmode/wscli/pkg/script"
 	"github.com/akshaykhairmode/wscli/pkg/terminal"
+	"github.com/akshaykhairmode/wscli/pkg/tunnel"
 	"github.com/akshaykhairmode/wscli/pkg/ws"
 )
 
@@ -26,11 +36,23 @@ func main() {
 
 	logger.Init(os.Stdout, nil)
 
+	//exit code of --script and --exec, the process exits with it once the other defers ran.
+	code := 0
+	defer func() {
+		if code != 0 {
+			os.Exit(code)
+		}
+	}()
+
 	if config.Flags.Version {
 		fmt.Printf("CLI Version : %s\n", CLIVersion)
 		return
 	}
 
+	if err := config.Flags.Validate(); err != nil {
+		logger.Fatal().Err(err).Msg("invalid flags")
+	}
+
 	if config.Flags.IsPerf {
 		gen, err := perf.New()
 		if err != nil {
@@ -41,17 +63,224 @@ func main() {
 		return
 	}
 
-	conn, closeFunc, readFunc, err := ws.Connect()
-	if err != nil {
+	if config.Flags.RecordFile != "" {
+		closeRecord, err := record.Init(config.Flags.RecordFile)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("record err")
+		}
+
+		defer func() {
+			if err := closeRecord(); err != nil {
+				logger.Debug().Err(err).Msg("error while closing the record file")
+			}
+		}()
+	}
+
+	if err := ws.SetFilter(config.Flags.Filter); err != nil {
+		logger.Fatal().Err(err).Msg("filter err")
+	}
+
+	if err := ws.SetCorrelate(config.Flags.Correlate.Field); err != nil {
+		logger.Fatal().Err(err).Msg("correlate err")
+	}
+
+	if config.Flags.Codec.Decode != "" {
+		decoder, err := codec.NewDecoder(config.Flags.Codec.Decode, config.Flags.Codec.ProtoDescriptor, config.Flags.Codec.ProtoMessage)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("decoder err")
+		}
+
+		ws.SetDecoder(decoder)
+	}
+
+	if config.Flags.Codec.Encode != "" {
+		encoder, err := codec.NewEncoder(config.Flags.Codec.Encode, config.Flags.Codec.ProtoDescriptor, config.Flags.Codec.SendMessageType())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("encoder err")
+		}
+
+		ws.SetEncoder(encoder)
+	}
+
+	if config.Flags.Serve {
+		runServe()
+		return
+	}
+
+	if config.Flags.Forward != "" || config.Flags.ForwardTo != "" {
+		runTunnel()
+		return
+	}
+
+	if config.Flags.Listen != "" && config.Flags.ConnectURL != "" {
+		runRelay()
+		return
+	}
+
+	if config.Flags.Listen != "" {
+		runListen()
+		return
+	}
+
+	client := ws.NewClient()
+	if config.Flags.Protocol.Name != "" {
+		if _, err := protocol.Attach(config.Flags.Protocol.Name, client); err != nil {
+			logger.Fatal().Err(err).Msg("protocol err")
+		}
+	}
+
+	//the script listens before connecting, so that the messages sent on connect can be expected.
+	var steps []script.Step
+	var runner *script.Runner
+	if config.Flags.ScriptFile != "" {
+		var err error
+		if steps, err = script.Load(config.Flags.ScriptFile); err != nil {
+			logger.Fatal().Err(err).Msg("script err")
+		}
+		runner = script.NewRunner(client)
+	}
+
+	if err := client.Connect(config.Flags.ConnectURL); err != nil {
 		logger.Fatal().Err(err).Msg("connect err")
 	}
 
-	defer closeFunc()
+	defer client.Close()
+
+	if runner != nil {
+		code = runScript(runner, steps)
+		return
+	}
 
-	go readFunc(conn)
+	if config.Flags.Exec != "" {
+		code = runExec(client)
+		return
+	}
+
+	if config.Flags.Replay.File != "" {
+		if err := processer.Replay(client); err != nil {
+			logger.Fatal().Err(err).Msg("replay err")
+		}
+		return
+	}
 
 	if config.Flags.ShouldProcessAsCmd() {
-		processer.ProcessAsCmd(conn)
+		processer.ProcessAsCmd(client)
+		return
+	}
+
+	term, closef, wg := terminal.New()
+	defer func() {
+		if err := closef(); err != nil {
+			logger.Debug().Err(err).Msg("error while closing readline")
+		}
+	}()
+
+	go func() {
+		global.WaitForStop()
+		term.Close()
+	}()
+
+	//the deferred close also closes the connections opened with /open.
+	i := processer.New(client, term)
+	defer i.Close()
+
+	i.Process()
+
+	term.Reader(wg)
+
+	fmt.Println()
+}
+
+// runServe runs the mock server until it is interrupted.
+func runServe() {
+	rules, err := mock.Load(config.Flags.RulesFile)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("rules err")
+	}
+
+	addr := cmp.Or(config.Flags.Listen, rules.Listen, ":8080")
+
+	closeServer, err := mock.New(rules).Serve(addr)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("serve err")
+	}
+	defer closeServer()
+
+	log.Println(ws.GreenColor("Serving %s on %s", config.Flags.RulesFile, addr))
+
+	waitForInterrupt()
+}
+
+// runTunnel forwards the byte streams of --forward or --forward-to until it is interrupted.
+func runTunnel() {
+	from, to, start := config.Flags.Forward, config.Flags.ConnectURL, tunnel.Forward
+	if config.Flags.ForwardTo != "" {
+		from, to, start = config.Flags.Listen, config.Flags.ForwardTo, tunnel.Reverse
+	}
+
+	closeTunnel, err := start(from, to)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("forward err")
+	}
+	defer closeTunnel()
+
+	log.Println(ws.GreenColor("Forwarding %s to %s", from, to))
+
+	waitForInterrupt()
+}
+
+func waitForInterrupt() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	<-sigs
+}
+
+// runRelay relays the accepted clients to the connect url, typed lines are injected into the sessions.
+func runRelay() {
+	r, err := relay.New(config.Flags.ConnectURL, config.Flags.RelayRules)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("relay rules err")
+	}
+
+	closeRelay, err := r.Listen(config.Flags.Listen)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("listen err")
+	}
+	defer closeRelay()
+
+	if config.Flags.IsSTDin {
+		log.Println(ws.GreenColor("Relaying %s to %s", config.Flags.Listen, config.Flags.ConnectURL))
+		waitForInterrupt()
+		return
+	}
+
+	term, closef, wg := terminal.New()
+	defer func() {
+		if err := closef(); err != nil {
+			logger.Debug().Err(err).Msg("error while closing readline")
+		}
+	}()
+
+	log.Println(ws.GreenColor("Relaying %s to %s, type /help for the commands", config.Flags.Listen, config.Flags.ConnectURL))
+
+	terminal.AddCompletions("/server", "/client", "/rules", "/reload")
+	term.OnMessage(r.Handle)
+	term.Reader(wg)
+
+	fmt.Println()
+}
+
+func runListen() {
+	if config.Flags.IsSTDin {
+		i := processer.NewListener(config.Flags.Listen, nil)
+		closeListen, err := i.Listen()
+		if err != nil {
+			logger.Fatal().Err(err).Msg("listen err")
+		}
+		defer closeListen()
+		defer i.Close()
+
+		global.WaitForStop()
 		return
 	}
 
@@ -67,9 +296,35 @@ func main() {
 		term.Close()
 	}()
 
-	processer.New(conn, term).Process()
+	i := processer.NewListener(config.Flags.Listen, term)
+	closeListen, err := i.Listen()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("listen err")
+	}
+	defer closeListen()
+	defer i.Close()
 
 	term.Reader(wg)
 
 	fmt.Println()
 }
+
+// runExec binds the --exec command to the connection and returns its exit code.
+func runExec(client *ws.Client) int {
+	code, err := processer.Exec(client, config.Flags.Exec)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("exec err")
+	}
+
+	return code
+}
+
+// runScript returns 1 if an expectation of the script was not met.
+func runScript(runner *script.Runner, steps []script.Step) int {
+	if err := runner.Run(steps); err != nil {
+		log.Println(ws.RedColor("✗ %s", err))
+		return 1
+	}
+
+	return 0
+}
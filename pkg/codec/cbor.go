@@ -0,0 +1,28 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+type cborCodec struct{}
+
+func (cborCodec) Decode(data []byte) ([]byte, error) {
+	var v any
+	if err := cbor.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("error while decoding cbor : %w", err)
+	}
+
+	return json.Marshal(jsonValue(v))
+}
+
+func (cborCodec) Encode(data []byte) ([]byte, error) {
+	v, err := parseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return cbor.Marshal(v)
+}
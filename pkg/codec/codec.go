@@ -0,0 +1,135 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	Base64   = "base64"
+	MsgPack  = "msgpack"
+	CBOR     = "cbor"
+	Protobuf = "protobuf"
+)
+
+// Decoder converts a binary message to a printable form, JSON for the structured formats.
+type Decoder interface {
+	Decode(data []byte) ([]byte, error)
+}
+
+// Encoder converts a message typed as JSON (base64 for Base64) to the binary format.
+type Encoder interface {
+	Encode(data []byte) ([]byte, error)
+}
+
+// NewDecoder returns the decoder for name. descriptorFile and messageType are only used by protobuf.
+func NewDecoder(name, descriptorFile, messageType string) (Decoder, error) {
+	switch name {
+	case Base64:
+		return base64Codec{}, nil
+	case MsgPack:
+		return msgpackCodec{}, nil
+	case CBOR:
+		return cborCodec{}, nil
+	case Protobuf:
+		return newProtobufCodec(descriptorFile, messageType)
+	}
+
+	return nil, fmt.Errorf("invalid decoder: %s. Use base64, msgpack, cbor or protobuf", name)
+}
+
+// NewEncoder returns the encoder for name. descriptorFile and messageType are only used by protobuf.
+func NewEncoder(name, descriptorFile, messageType string) (Encoder, error) {
+	switch name {
+	case Base64:
+		return base64Codec{}, nil
+	case MsgPack:
+		return msgpackCodec{}, nil
+	case CBOR:
+		return cborCodec{}, nil
+	case Protobuf:
+		return newProtobufCodec(descriptorFile, messageType)
+	}
+
+	return nil, fmt.Errorf("invalid encoder: %s. Use base64, msgpack, cbor or protobuf", name)
+}
+
+type base64Codec struct{}
+
+func (base64Codec) Decode(data []byte) ([]byte, error) {
+	return []byte(base64.StdEncoding.EncodeToString(data)), nil
+}
+
+func (base64Codec) Encode(data []byte) ([]byte, error) {
+	dec, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return nil, fmt.Errorf("error while decoding base64 : %w", err)
+	}
+	return dec, nil
+}
+
+// parseJSON decodes a JSON message for the msgpack and cbor encoders.
+// Integers are kept as int64 so they are not encoded as floats.
+func parseJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("message is not valid JSON : %w", err)
+	}
+
+	if dec.More() {
+		return nil, fmt.Errorf("message is not valid JSON : unexpected data after the value")
+	}
+
+	return numbers(v), nil
+}
+
+func numbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for key, val := range v {
+			v[key] = numbers(val)
+		}
+	case []any:
+		for i, val := range v {
+			v[i] = numbers(val)
+		}
+	}
+
+	return v
+}
+
+// jsonValue converts the values returned by the msgpack and cbor decoders to values encoding/json can marshal.
+// Maps with non string keys are converted to maps with the keys formatted as strings.
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for key, val := range v {
+			m[fmt.Sprint(key)] = jsonValue(val)
+		}
+		return m
+	case map[string]any:
+		for key, val := range v {
+			v[key] = jsonValue(val)
+		}
+		return v
+	case []any:
+		for i, val := range v {
+			v[i] = jsonValue(val)
+		}
+		return v
+	}
+
+	return v
+}
@@ -0,0 +1,215 @@
+package codec
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var g, w any
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("output %q is not JSON: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("invalid want %q: %v", want, err)
+	}
+
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("Decode() = %s, want %s", got, want)
+	}
+}
+
+func TestNewDecoderInvalid(t *testing.T) {
+	if _, err := NewDecoder("xml", "", ""); err == nil {
+		t.Error("NewDecoder() with invalid name should return error")
+	}
+
+	if _, err := NewDecoder(Protobuf, "", ""); err == nil {
+		t.Error("NewDecoder() for protobuf without descriptor should return error")
+	}
+}
+
+func TestBase64(t *testing.T) {
+	d, _ := NewDecoder(Base64, "", "")
+	got, err := d.Decode([]byte{0xde, 0xad, 0xbe, 0xef})
+	if err != nil || string(got) != "3q2+7w==" {
+		t.Errorf("Decode() = %q, %v, want 3q2+7w==", got, err)
+	}
+}
+
+func TestMsgPack(t *testing.T) {
+	data, err := msgpack.Marshal(map[any]any{"type": "trade", "price": 1.5, 1: []any{true, nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, _ := NewDecoder(MsgPack, "", "")
+	got, err := d.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+
+	assertJSON(t, got, `{"type":"trade","price":1.5,"1":[true,null]}`)
+
+	if _, err := d.Decode([]byte{0xc1}); err == nil {
+		t.Error("Decode() with invalid msgpack should return error")
+	}
+
+	if got, err := d.Decode([]byte{0x01, 0xff, 0xfe, 0x00, 0x13}); err == nil {
+		t.Errorf("Decode() with trailing bytes = %s, want error", got)
+	}
+}
+
+func TestCBOR(t *testing.T) {
+	data, err := cbor.Marshal(map[any]any{"id": 7, 2: "two"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, _ := NewDecoder(CBOR, "", "")
+	got, err := d.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+
+	assertJSON(t, got, `{"id":7,"2":"two"}`)
+
+	if _, err := d.Decode([]byte{0xff}); err == nil {
+		t.Error("Decode() with invalid cbor should return error")
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	const msg = `{"type":"order","qty":3,"price":1.25,"tags":["a",null,true],"meta":{}}`
+
+	for _, name := range []string{MsgPack, CBOR} {
+		e, err := NewEncoder(name, "", "")
+		if err != nil {
+			t.Fatalf("NewEncoder(%s) error: %v", name, err)
+		}
+
+		data, err := e.Encode([]byte(msg))
+		if err != nil {
+			t.Fatalf("%s Encode() error: %v", name, err)
+		}
+
+		d, _ := NewDecoder(name, "", "")
+		got, err := d.Decode(data)
+		if err != nil {
+			t.Fatalf("%s Decode() error: %v", name, err)
+		}
+
+		assertJSON(t, got, msg)
+
+		if _, err := e.Encode([]byte("not json")); err == nil {
+			t.Errorf("%s Encode() with invalid JSON should return error", name)
+		}
+	}
+}
+
+func TestEncodeIntegers(t *testing.T) {
+	e, _ := NewEncoder(MsgPack, "", "")
+	got, err := e.Encode([]byte("1"))
+	if err != nil || len(got) != 1 || got[0] != 0x01 {
+		t.Errorf("msgpack Encode(1) = %x, %v, want 01", got, err)
+	}
+
+	e, _ = NewEncoder(CBOR, "", "")
+	got, err = e.Encode([]byte("-2"))
+	if err != nil || len(got) != 1 || got[0] != 0x21 {
+		t.Errorf("cbor Encode(-2) = %x, %v, want 21", got, err)
+	}
+}
+
+func TestBase64Encode(t *testing.T) {
+	e, _ := NewEncoder(Base64, "", "")
+	got, err := e.Encode([]byte("3q2+7w==\n"))
+	if err != nil || string(got) != "\xde\xad\xbe\xef" {
+		t.Errorf("Encode() = %x, %v, want deadbeef", got, err)
+	}
+
+	if _, err := e.Encode([]byte("!!")); err == nil {
+		t.Error("Encode() with invalid base64 should return error")
+	}
+}
+
+func TestProtobuf(t *testing.T) {
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(timestamppb.File_google_protobuf_timestamp_proto)},
+	}
+
+	setData, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "set.pb")
+	if err := os.WriteFile(path, setData, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewDecoder(Protobuf, path, "google.protobuf.Missing"); err == nil {
+		t.Error("NewDecoder() with unknown message type should return error")
+	}
+
+	d, err := NewDecoder(Protobuf, path, "google.protobuf.Timestamp")
+	if err != nil {
+		t.Fatalf("NewDecoder() error: %v", err)
+	}
+
+	data, err := proto.Marshal(&timestamppb.Timestamp{Seconds: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := d.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+
+	assertJSON(t, got, `"1970-01-01T00:00:10Z"`)
+
+	e, err := NewEncoder(Protobuf, path, "google.protobuf.Timestamp")
+	if err != nil {
+		t.Fatalf("NewEncoder() error: %v", err)
+	}
+
+	enc, err := e.Encode([]byte(`"1970-01-01T00:00:10Z"`))
+	if err != nil {
+		t.Fatalf("Encode() error: %v", err)
+	}
+
+	if !proto.Equal(mustTimestamp(t, enc), &timestamppb.Timestamp{Seconds: 10}) {
+		t.Errorf("Encode() = %x, want timestamp with 10 seconds", enc)
+	}
+
+	if _, err := e.Encode([]byte(`{"unknown":1}`)); err == nil {
+		t.Error("Encode() with unknown field should return error")
+	}
+
+	if _, err := d.Decode([]byte{0xff}); err == nil {
+		t.Error("Decode() with invalid protobuf should return error")
+	}
+}
+
+func mustTimestamp(t *testing.T, data []byte) *timestamppb.Timestamp {
+	t.Helper()
+
+	ts := &timestamppb.Timestamp{}
+	if err := proto.Unmarshal(data, ts); err != nil {
+		t.Fatalf("proto.Unmarshal() error: %v", err)
+	}
+	return ts
+}
@@ -0,0 +1,51 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Decode(data []byte) ([]byte, error) {
+	r := bytes.NewReader(data)
+	dec := msgpack.NewDecoder(r)
+	dec.UseLooseInterfaceDecoding(true)
+	//keys are not always strings, decode them as any and convert them in jsonValue.
+	dec.SetMapDecoder(func(d *msgpack.Decoder) (any, error) {
+		return d.DecodeUntypedMap()
+	})
+
+	v, err := dec.DecodeInterface()
+	if err != nil {
+		return nil, fmt.Errorf("error while decoding msgpack : %w", err)
+	}
+
+	//e.g. any frame starting with 0x00-0x7f decodes to a fixint.
+	if r.Len() > 0 {
+		return nil, fmt.Errorf("error while decoding msgpack : extraneous data, %d bytes left", r.Len())
+	}
+
+	return json.Marshal(jsonValue(v))
+}
+
+func (msgpackCodec) Encode(data []byte) ([]byte, error) {
+	v, err := parseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.UseCompactInts(true)
+	enc.UseCompactFloats(true)
+
+	if err := enc.Encode(v); err != nil {
+		return nil, fmt.Errorf("error while encoding msgpack : %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,70 @@
+package codec
+
+import (
+	"fmt"
+	"os"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+type protobufCodec struct {
+	desc protoreflect.MessageDescriptor
+}
+
+// newProtobufCodec loads messageType from a descriptor set file, as written by
+// protoc --include_imports --descriptor_set_out=<file>.
+func newProtobufCodec(descriptorFile, messageType string) (*protobufCodec, error) {
+	if descriptorFile == "" || messageType == "" {
+		return nil, fmt.Errorf("protobuf needs a descriptor set file and a message type")
+	}
+
+	data, err := os.ReadFile(descriptorFile)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading the descriptor set : %w", err)
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("error while parsing the descriptor set : %w", err)
+	}
+
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading the descriptor set : %w", err)
+	}
+
+	d, err := files.FindDescriptorByName(protoreflect.FullName(messageType))
+	if err != nil {
+		return nil, fmt.Errorf("message type %s : %w", messageType, err)
+	}
+
+	desc, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message type", messageType)
+	}
+
+	return &protobufCodec{desc: desc}, nil
+}
+
+func (p *protobufCodec) Decode(data []byte) ([]byte, error) {
+	msg := dynamicpb.NewMessage(p.desc)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("error while decoding protobuf : %w", err)
+	}
+
+	return protojson.Marshal(msg)
+}
+
+func (p *protobufCodec) Encode(data []byte) ([]byte, error) {
+	msg := dynamicpb.NewMessage(p.desc)
+	if err := protojson.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("error while parsing the %s message : %w", p.desc.FullName(), err)
+	}
+
+	return proto.Marshal(msg)
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
@@ -24,8 +25,25 @@ type Flag struct {
 	SubProtocol         []string
 	Proxy               string
 	UnixSocket          string
-
-	Perf Perf
+	RecordFile          string
+	Timestamp           string
+	Filter              string
+	ScriptFile          string
+	Listen              string //address to accept connections on instead of connecting, e.g. :8080/ws. With ConnectURL it relays.
+	Serve               bool   //wscli serve <rules file>, run the mock server.
+	RulesFile           string //rules file of the mock server.
+	RelayRules          string //rewrite rules of the relay, --listen with --connect.
+	Forward             string //local TCP address or unix:<path> forwarded to ConnectURL.
+	ForwardTo           string //TCP address or unix:<path> the connections accepted on Listen are forwarded to.
+	Exec                string //command whose stdout is sent and stdin receives the messages.
+	ExecRaw             bool   //exec in binary chunks instead of lines.
+
+	Perf      Perf
+	Reconnect Reconnect
+	Replay    Replay
+	Codec     Codec
+	Correlate Correlate
+	Protocol  Protocol
 
 	ShowPingPong              bool
 	IsSlash                   bool
@@ -38,6 +56,8 @@ type Flag struct {
 	IsBinary                  bool
 	IsGzipResponse            bool
 	IsPerf                    bool
+	Compress                  bool
+	Template                  bool
 
 	IsStdOut bool
 
@@ -47,6 +67,56 @@ type Flag struct {
 	TLS TLS
 }
 
+const (
+	TimestampWall     = "wall"     //wall clock time.
+	TimestampRelative = "relative" //time since the connection was established.
+	TimestampDelta    = "delta"    //time since the previous sent or received message.
+)
+
+type Reconnect struct {
+	Enabled  bool
+	Attempts uint          //0 means retry forever.
+	Delay    time.Duration //delay before the first attempt, doubled after every failed attempt.
+	MaxDelay time.Duration
+}
+
+type Replay struct {
+	File  string
+	Speed float64 //1 keeps the recorded timing, 2 is twice as fast, 0 sends without delay.
+}
+
+type Protocol struct {
+	Name      string //application protocol framed on top of the messages, see the protocol package.
+	Payload   string //JSON sent with the protocol handshake.
+	Namespace string //socket.io namespace.
+
+	Headers   []string      //headers added to the handshake frame, e.g. the STOMP login and passcode.
+	Heartbeat time.Duration //interval of the protocol heart-beats sent by the client.
+	Timeout   time.Duration //how long to wait for protocol handshakes and replies.
+}
+
+type Correlate struct {
+	Field   string        //dot separated JSON field used to match replies to sent messages.
+	Timeout time.Duration //time after which a sent message without reply is reported.
+}
+
+type Codec struct {
+	Decode          string //how binary messages are printed: base64, msgpack, cbor or protobuf. Hex if empty.
+	ProtoDescriptor string //descriptor set file, used by protobuf.
+	ProtoMessage    string //fully qualified message type, used by protobuf.
+
+	Encode           string //how sent text messages are encoded to binary: base64, msgpack, cbor or protobuf. Sent as is if empty.
+	ProtoSendMessage string //fully qualified message type of sent messages, ProtoMessage if empty.
+}
+
+// SendMessageType returns the protobuf message type used to encode sent messages.
+func (c Codec) SendMessageType() string {
+	if c.ProtoSendMessage != "" {
+		return c.ProtoSendMessage
+	}
+	return c.ProtoMessage
+}
+
 type TLS struct {
 	CA         string
 	Cert       string
@@ -106,22 +176,55 @@ func get() *Flag {
 	pflag.BoolVar(&cfg.IsJSONPrettyPrint, "jspp", false, "Enable JSON pretty printing for responses.")
 	pflag.BoolVarP(&cfg.IsBinary, "binary", "b", false, "Send hex encoded data to server")
 	pflag.BoolVar(&cfg.IsGzipResponse, "gzipr", false, "Enable gzip decoding if server messages are gzip-encoded. (Note: Server must send messages as binary.)")
+	pflag.StringVar(&cfg.Protocol.Name, "protocol", "", "Speak an application protocol on top of WebSocket: socketio, stomp, graphql, jsonrpc, phoenix, actioncable or signalr.")
+	pflag.StringSliceVar(&cfg.Protocol.Headers, "protocol-header", []string{}, "Header added to the protocol handshake (key:value, e.g. login:guest for STOMP), can be used multiple times.")
+	pflag.DurationVar(&cfg.Protocol.Timeout, "protocol-timeout", 10*time.Second, "How long to wait for the protocol handshake and for replies to protocol calls.")
+	pflag.DurationVar(&cfg.Protocol.Heartbeat, "heartbeat", 10*time.Second, "Interval of the protocol heart-beats, 0 disables them.")
+	pflag.StringVar(&cfg.Protocol.Payload, "protocol-payload", "", "JSON payload sent with the protocol handshake (socket.io auth, graphql connection_init payload).")
+	pflag.StringVar(&cfg.Protocol.Namespace, "sio-namespace", "/", "Socket.IO namespace to connect to, used with --protocol socketio.")
+	pflag.StringVar(&cfg.Correlate.Field, "correlate", "", "JSON field (e.g. id or meta.requestId) matching replies to sent messages, replies are shown with the round trip time.")
+	pflag.DurationVar(&cfg.Correlate.Timeout, "correlate-timeout", 10*time.Second, "Report sent messages which got no reply within this duration, used with --correlate.")
+	pflag.StringVar(&cfg.Codec.Decode, "decode", "", "Decode received binary messages as base64, msgpack, cbor or protobuf. Falls back to hex if decoding fails.")
+	pflag.StringVar(&cfg.Codec.ProtoDescriptor, "proto-descriptor", "", "Protobuf descriptor set file (protoc --include_imports --descriptor_set_out), used with --decode protobuf.")
+	pflag.StringVar(&cfg.Codec.ProtoMessage, "proto-message", "", "Fully qualified protobuf message type (e.g. chat.v1.Event), used with --decode protobuf.")
+	pflag.StringVar(&cfg.Codec.Encode, "encode", "", "Encode sent JSON messages as msgpack, cbor or protobuf (or base64 input) and send them as binary messages.")
+	pflag.StringVar(&cfg.Codec.ProtoSendMessage, "proto-send-message", "", "Fully qualified protobuf message type of sent messages, used with --encode protobuf. Default is --proto-message.")
+	pflag.BoolVar(&cfg.Template, "template", false, "Execute interactive, -x and piped messages as templates (see the load message template functions).")
+	pflag.BoolVar(&cfg.Compress, "compress", false, "Negotiate permessage-deflate compression (RFC 7692) with the server.")
 	pflag.BoolVar(&cfg.IsStdOut, "std-out", false, "print the received messages in standard output, default is standard error")
 
 	pflag.StringVarP(&cfg.ConnectURL, "connect", "c", "", "WebSocket connection URL.")
+	pflag.StringVarP(&cfg.Listen, "listen", "l", "", "Run as a WebSocket server accepting connections on the address, e.g. :8080 or :8080/ws. With --connect, every accepted client is relayed to the connect url.")
+	pflag.StringVar(&cfg.RelayRules, "relay-rules", "", "Rewrite, drop or delay the frames relayed with --listen and --connect according to the rules of a YAML file.")
+	pflag.StringVar(&cfg.Forward, "forward", "", "Accept TCP connections on the address (or unix:<path>) and carry every one of them as binary frames over a WebSocket connection to the connect url.")
+	pflag.StringVar(&cfg.ForwardTo, "forward-to", "", "Pipe every WebSocket connection accepted with --listen to the TCP address (or unix:<path>).")
+	pflag.StringVar(&cfg.Exec, "exec", "", "Run a command, send every line of its stdout and write every received message to its stdin. wscli exits with the command.")
+	pflag.BoolVar(&cfg.ExecRaw, "exec-raw", false, "Send the output of --exec in binary chunks and write the received messages to it without a newline.")
 	pflag.StringVar(&cfg.BindAddress, "bind-address", "", "Bind address for outgoing connection (e.g., 192.168.1.100).")
 	pflag.StringVar(&cfg.IPVersion, "ip-version", "", "IP version to use for outgoing connection (4 or 6).")
 	pflag.StringVar(&cfg.Proxy, "proxy", "", "Use a proxy URL.")
 	pflag.StringVar(&cfg.UnixSocket, "unix-socket", "", "Connect to a Unix domain socket.")
+	pflag.StringVarP(&cfg.Timestamp, "timestamp", "t", "", "Prefix sent and received messages with a timestamp (wall, relative or delta).")
+	pflag.StringVar(&cfg.Filter, "filter", "", "jq expression applied to received JSON messages, only matching messages or the projected values are shown.")
+	pflag.StringVar(&cfg.ScriptFile, "script", "", "Run a send/expect script file and exit with a non-zero code if an expectation is not met.")
+	pflag.StringVar(&cfg.RecordFile, "record", "", "Record every sent and received frame to a file as JSON lines.")
 	pflag.StringVar(&cfg.Auth, "auth", "", "HTTP Basic Authentication credentials (e.g., username:password).")
 	pflag.StringSliceVarP(&cfg.Headers, "header", "H", []string{}, "Custom headers (key:value, can be used multiple times).")
 	pflag.StringVarP(&cfg.Origin, "origin", "o", "", "Specify origin for the WebSocket connection (optional).")
 	pflag.StringSliceVarP(&cfg.Execute, "execute", "x", []string{}, "Execute a command after connecting (use multiple times for multiple commands).")
-	pflag.DurationVarP(&cfg.Wait, "wait", "w", 0, "Wait time after command execution (1s, 1m, 1h).")
+	pflag.DurationVarP(&cfg.Wait, "wait", "w", 0, "Wait time after command execution (1s, 1m, 1h), 2s by default with --replay.")
 	pflag.StringSliceVarP(&cfg.SubProtocol, "sub-protocol", "s", []string{}, "Specify a sub-protocol for the WebSocket connection (optional, can be used multiple times).")
 	pflag.DurationVar(&cfg.PrintOutputInterval, "print-interval", time.Second, "how often to print the status on the terminal")
 	pflag.DurationVar(&cfg.PingInterval, "ping-interval", 30*time.Second, "how often to ping the connections which are created")
 
+	pflag.BoolVar(&cfg.Reconnect.Enabled, "reconnect", false, "Reconnect with exponential backoff when the connection drops.")
+	pflag.UintVar(&cfg.Reconnect.Attempts, "reconnect-attempts", 0, "Maximum reconnect attempts, 0 means retry forever.")
+	pflag.DurationVar(&cfg.Reconnect.Delay, "reconnect-delay", time.Second, "Delay before the first reconnect attempt, doubled after every failed attempt.")
+	pflag.DurationVar(&cfg.Reconnect.MaxDelay, "reconnect-max-delay", 30*time.Second, "Maximum delay between reconnect attempts.")
+
+	pflag.StringVar(&cfg.Replay.File, "replay", "", "Replay the sent frames of a recorded session (see --record) to the connect url.")
+	pflag.Float64Var(&cfg.Replay.Speed, "replay-speed", 1, "Replay speed factor, 2 is twice as fast, 0 sends without delay.")
+
 	pflag.StringVar(&cfg.TLS.CA, "ca", "", "Path to the CA certificate file (optional).")
 	pflag.StringVar(&cfg.TLS.Cert, "cert", "", "Path to the client certificate file (optional).")
 	pflag.StringVar(&cfg.TLS.Key, "key", "", "Path to the certificate key file (optional).")
@@ -142,6 +245,10 @@ func get() *Flag {
 
 	pflag.Parse()
 
+	if pflag.Arg(0) == "serve" {
+		cfg.Serve, cfg.RulesFile = true, pflag.Arg(1)
+	}
+
 	if cfg.Help {
 		pflag.Usage()
 		os.Exit(0)
@@ -176,11 +283,101 @@ func isInputFromPipe() bool {
 	return (fileInfo.Mode() & os.ModeCharDevice) == 0
 }
 
+// Validate checks the flags which only accept a fixed set of values.
+func (c *Flag) Validate() error {
+	switch c.Timestamp {
+	case "", TimestampWall, TimestampRelative, TimestampDelta:
+	default:
+		return fmt.Errorf("invalid timestamp: %s. Use wall, relative or delta", c.Timestamp)
+	}
+
+	switch c.Codec.Decode {
+	case "", "base64", "msgpack", "cbor":
+	case "protobuf":
+		if c.Codec.ProtoDescriptor == "" || c.Codec.ProtoMessage == "" {
+			return fmt.Errorf("--decode protobuf needs --proto-descriptor and --proto-message")
+		}
+	default:
+		return fmt.Errorf("invalid decode: %s. Use base64, msgpack, cbor or protobuf", c.Codec.Decode)
+	}
+
+	switch c.Codec.Encode {
+	case "", "base64", "msgpack", "cbor":
+	case "protobuf":
+		if c.Codec.ProtoDescriptor == "" || c.Codec.SendMessageType() == "" {
+			return fmt.Errorf("--encode protobuf needs --proto-descriptor and --proto-send-message or --proto-message")
+		}
+	default:
+		return fmt.Errorf("invalid encode: %s. Use base64, msgpack, cbor or protobuf", c.Codec.Encode)
+	}
+
+	switch c.Protocol.Name {
+	case "", "socketio", "stomp", "graphql", "jsonrpc", "phoenix", "actioncable", "signalr":
+	default:
+		return fmt.Errorf("invalid protocol: %s. Use socketio, stomp, graphql, jsonrpc, phoenix, actioncable or signalr", c.Protocol.Name)
+	}
+
+	for _, h := range c.Protocol.Headers {
+		if !strings.Contains(h, ":") {
+			return fmt.Errorf("invalid protocol header : %s", h)
+		}
+	}
+
+	if c.Protocol.Payload != "" && !json.Valid([]byte(c.Protocol.Payload)) {
+		return fmt.Errorf("--protocol-payload is not valid JSON")
+	}
+
+	if c.Correlate.Field != "" && c.Correlate.Timeout <= 0 {
+		return fmt.Errorf("--correlate-timeout must be greater than 0")
+	}
+
+	if c.Codec.Encode != "" && c.IsBinary {
+		return fmt.Errorf("--encode cannot be used with --binary")
+	}
+
+	if c.Listen != "" && (c.Protocol.Name != "" || c.IsPerf) {
+		return fmt.Errorf("--listen cannot be used with --protocol or --perf")
+	}
+
+	if c.RelayRules != "" && (c.Listen == "" || c.ConnectURL == "") {
+		return fmt.Errorf("--relay-rules needs --listen and --connect")
+	}
+
+	if c.Forward != "" && (c.ConnectURL == "" || c.Listen != "" || c.Protocol.Name != "") {
+		return fmt.Errorf("--forward needs --connect and cannot be used with --listen or --protocol")
+	}
+
+	if c.ForwardTo != "" && (c.Listen == "" || c.ConnectURL != "") {
+		return fmt.Errorf("--forward-to needs --listen and cannot be used with --connect")
+	}
+
+	if c.Exec != "" && (c.Listen != "" || c.Forward != "" || c.ScriptFile != "" || c.Replay.File != "" || c.IsPerf) {
+		return fmt.Errorf("--exec cannot be used with --listen, --forward, --script, --replay or --perf")
+	}
+
+	if c.ExecRaw && (c.Exec == "" || c.IsBinary) {
+		return fmt.Errorf("--exec-raw needs --exec and cannot be used with --binary")
+	}
+
+	if c.Serve && c.RulesFile == "" {
+		return fmt.Errorf("rules file is missing, usage: wscli serve <rules.yaml>")
+	}
+
+	return nil
+}
+
 func (c *Flag) String() string {
 	var sb strings.Builder
 
 	sb.WriteString("Config:\n")
 	sb.WriteString(fmt.Sprintf("  ConnectURL: %s\n", c.ConnectURL))
+	sb.WriteString(fmt.Sprintf("  Listen: %s\n", c.Listen))
+	sb.WriteString(fmt.Sprintf("  RulesFile: %s\n", c.RulesFile))
+	sb.WriteString(fmt.Sprintf("  RelayRules: %s\n", c.RelayRules))
+	sb.WriteString(fmt.Sprintf("  Forward: %s\n", c.Forward))
+	sb.WriteString(fmt.Sprintf("  ForwardTo: %s\n", c.ForwardTo))
+	sb.WriteString(fmt.Sprintf("  Exec: %s\n", c.Exec))
+	sb.WriteString(fmt.Sprintf("  ExecRaw: %t\n", c.ExecRaw))
 	sb.WriteString(fmt.Sprintf("  BindAddress: %s\n", c.BindAddress))
 	sb.WriteString(fmt.Sprintf("  IPVersion: %s\n", c.IPVersion))
 	sb.WriteString(fmt.Sprintf("  Auth: %s\n", c.Auth))
@@ -192,6 +389,10 @@ func (c *Flag) String() string {
 	sb.WriteString(fmt.Sprintf("  PingInterval: %s\n", c.PingInterval))
 	sb.WriteString(fmt.Sprintf("  SubProtocol: %v\n", c.SubProtocol))
 	sb.WriteString(fmt.Sprintf("  Proxy: %s\n", c.Proxy))
+	sb.WriteString(fmt.Sprintf("  RecordFile: %s\n", c.RecordFile))
+	sb.WriteString(fmt.Sprintf("  Timestamp: %s\n", c.Timestamp))
+	sb.WriteString(fmt.Sprintf("  Filter: %s\n", c.Filter))
+	sb.WriteString(fmt.Sprintf("  ScriptFile: %s\n", c.ScriptFile))
 
 	sb.WriteString(fmt.Sprintf("  ShowPingPong: %t\n", c.ShowPingPong))
 	sb.WriteString(fmt.Sprintf("  IsSlash: %t\n", c.IsSlash))
@@ -204,12 +405,19 @@ func (c *Flag) String() string {
 	sb.WriteString(fmt.Sprintf("  IsBinary: %t\n", c.IsBinary))
 	sb.WriteString(fmt.Sprintf("  IsGzipResponse: %t\n", c.IsGzipResponse))
 	sb.WriteString(fmt.Sprintf("  IsPerf: %t\n", c.IsPerf))
+	sb.WriteString(fmt.Sprintf("  Compress: %t\n", c.Compress))
+	sb.WriteString(fmt.Sprintf("  Template: %t\n", c.Template))
 	sb.WriteString(fmt.Sprintf("  IsStdOut: %t\n", c.IsStdOut))
 
 	sb.WriteString(fmt.Sprintf("  Help: %t\n", c.Help))
 	sb.WriteString(fmt.Sprintf("  IsSTDin: %t\n", c.IsSTDin))
 
 	sb.WriteString(fmt.Sprintf("  TLS: %+v\n", c.TLS))
+	sb.WriteString(fmt.Sprintf("  Reconnect: %+v\n", c.Reconnect))
+	sb.WriteString(fmt.Sprintf("  Replay: %+v\n", c.Replay))
+	sb.WriteString(fmt.Sprintf("  Codec: %+v\n", c.Codec))
+	sb.WriteString(fmt.Sprintf("  Correlate: %+v\n", c.Correlate))
+	sb.WriteString(fmt.Sprintf("  Protocol: %+v\n", c.Protocol))
 	if c.IsPerf { // Added Perf details conditionally
 		sb.WriteString("  Perf Config:\n")
 		// Indent the Perf string output for better readability
@@ -89,3 +89,85 @@ func TestFlagString(t *testing.T) {
 		t.Errorf("Flag.String() missing Perf Config section: %q", s)
 	}
 }
+
+func TestValidate(t *testing.T) {
+	for _, ts := range []string{"", TimestampWall, TimestampRelative, TimestampDelta} {
+		if err := (&Flag{Timestamp: ts}).Validate(); err != nil {
+			t.Errorf("Validate() with timestamp %q returned error: %v", ts, err)
+		}
+	}
+
+	if err := (&Flag{Timestamp: "invalid"}).Validate(); err == nil {
+		t.Error("Validate() with invalid timestamp should return error")
+	}
+
+	if err := (&Flag{Codec: Codec{Decode: "xml"}}).Validate(); err == nil {
+		t.Error("Validate() with invalid decode should return error")
+	}
+
+	if err := (&Flag{Codec: Codec{Decode: "protobuf"}}).Validate(); err == nil {
+		t.Error("Validate() with protobuf decode and no descriptor should return error")
+	}
+
+	if err := (&Flag{Codec: Codec{Decode: "protobuf", ProtoDescriptor: "set.pb", ProtoMessage: "a.B"}}).Validate(); err != nil {
+		t.Errorf("Validate() with protobuf decode returned error: %v", err)
+	}
+
+	if err := (&Flag{Codec: Codec{Encode: "msgpack"}, IsBinary: true}).Validate(); err == nil {
+		t.Error("Validate() with encode and binary should return error")
+	}
+
+	if err := (&Flag{Codec: Codec{Encode: "protobuf", ProtoDescriptor: "set.pb", ProtoSendMessage: "a.Req"}}).Validate(); err != nil {
+		t.Errorf("Validate() with protobuf encode returned error: %v", err)
+	}
+
+	if err := (&Flag{Protocol: Protocol{Name: "mqtt"}}).Validate(); err == nil {
+		t.Error("Validate() with invalid protocol should return error")
+	}
+
+	if err := (&Flag{Protocol: Protocol{Name: "socketio", Payload: "{"}}).Validate(); err == nil {
+		t.Error("Validate() with invalid protocol payload should return error")
+	}
+
+	if err := (&Flag{Protocol: Protocol{Name: "stomp", Headers: []string{"login"}}}).Validate(); err == nil {
+		t.Error("Validate() with invalid protocol header should return error")
+	}
+
+	if err := (&Flag{Correlate: Correlate{Field: "id"}}).Validate(); err == nil {
+		t.Error("Validate() with correlate and no timeout should return error")
+	}
+
+	if err := (&Flag{Serve: true}).Validate(); err == nil {
+		t.Error("Validate() of serve without rules file should return error")
+	}
+
+	if err := (&Flag{Forward: ":5432"}).Validate(); err == nil {
+		t.Error("Validate() of forward without connect url should return error")
+	}
+
+	if err := (&Flag{ForwardTo: "localhost:5432", Listen: ":8080", ConnectURL: "ws://localhost"}).Validate(); err == nil {
+		t.Error("Validate() of forward-to with connect url should return error")
+	}
+
+	if err := (&Flag{ForwardTo: "localhost:5432", Listen: ":8080"}).Validate(); err != nil {
+		t.Errorf("Validate() of forward-to returned error: %v", err)
+	}
+
+	if err := (&Flag{Exec: "./bot", ScriptFile: "smoke.yaml"}).Validate(); err == nil {
+		t.Error("Validate() of exec with script should return error")
+	}
+
+	if err := (&Flag{ExecRaw: true}).Validate(); err == nil {
+		t.Error("Validate() of exec-raw without exec should return error")
+	}
+}
+
+func TestSendMessageType(t *testing.T) {
+	if got := (Codec{ProtoMessage: "a.Event"}).SendMessageType(); got != "a.Event" {
+		t.Errorf("SendMessageType() = %q, want a.Event", got)
+	}
+
+	if got := (Codec{ProtoMessage: "a.Event", ProtoSendMessage: "a.Req"}).SendMessageType(); got != "a.Req" {
+		t.Errorf("SendMessageType() = %q, want a.Req", got)
+	}
+}
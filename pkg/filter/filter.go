@@ -0,0 +1,154 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/itchyny/gojq"
+)
+
+// Filter is a compiled jq expression used to select and project JSON messages.
+type Filter struct {
+	expr string
+	code *gojq.Code
+}
+
+func New(expr string) (*Filter, error) {
+	query, err := gojq.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("error while parsing the filter : %w", err)
+	}
+
+	code, err := gojq.Compile(query)
+	if err != nil {
+		return nil, fmt.Errorf("error while compiling the filter : %w", err)
+	}
+
+	return &Filter{expr: expr, code: code}, nil
+}
+
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// Apply runs the filter on a JSON message and reports whether the message should be shown.
+// If the filter only selects (returns the input or true) the message is returned as is,
+// otherwise the projected values are returned one per line. Strings are returned without quotes.
+// Messages which are not JSON or for which the filter returns nothing, null or false are not shown.
+func (f *Filter) Apply(message []byte) ([]byte, bool) {
+
+	input, values, err := f.run(message)
+	if err != nil {
+		logger.Debug().Err(err).Msg("filter error")
+		return nil, false
+	}
+
+	var out [][]byte
+	matched := false
+
+	for _, v := range values {
+		switch val := v.(type) {
+		case nil:
+			continue
+		case bool:
+			matched = matched || val
+			continue
+		case map[string]any, []any:
+			//select() returns the input itself, print it as received.
+			if reflect.DeepEqual(val, input) {
+				matched = true
+				continue
+			}
+
+			enc, err := gojq.Marshal(val)
+			if err != nil {
+				logger.Debug().Err(err).Msg("error while marshalling the filter output")
+				continue
+			}
+			out = append(out, enc)
+		case string:
+			out = append(out, []byte(val))
+		default:
+			enc, err := gojq.Marshal(val)
+			if err != nil {
+				logger.Debug().Err(err).Msg("error while marshalling the filter output")
+				continue
+			}
+			out = append(out, enc)
+		}
+		matched = true
+	}
+
+	if !matched {
+		return nil, false
+	}
+
+	if len(out) == 0 {
+		return message, true
+	}
+
+	return bytes.Join(out, []byte("\n")), true
+}
+
+// run returns the decoded message and every value the filter produces for it.
+func (f *Filter) run(message []byte) (any, []any, error) {
+
+	input, err := decode(message)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var values []any
+
+	iter := f.code.Run(input)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+
+		if err, ok := v.(error); ok {
+			return nil, nil, err
+		}
+
+		values = append(values, v)
+	}
+
+	return input, values, nil
+}
+
+// Values returns every value the filter produces for the JSON message.
+func (f *Filter) Values(message []byte) ([]any, error) {
+	_, values, err := f.run(message)
+	return values, err
+}
+
+// Truthy reports whether the values of a filter contain one other than null or false.
+func Truthy(values []any) bool {
+	for _, v := range values {
+		if v != nil && v != false {
+			return true
+		}
+	}
+	return false
+}
+
+func decode(message []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(message))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("message is not json : %w", err)
+	}
+
+	if dec.More() {
+		return nil, fmt.Errorf("message is not json : %s", strings.TrimSpace(string(message)))
+	}
+
+	return v, nil
+}
@@ -0,0 +1,72 @@
+package filter
+
+import (
+	"io"
+	"testing"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/logger"
+)
+
+func TestNewInvalid(t *testing.T) {
+	if _, err := New(".a |"); err == nil {
+		t.Error("New() with invalid expression should return error")
+	}
+}
+
+func TestApply(t *testing.T) {
+	cases := []struct {
+		expr    string
+		message string
+		want    string
+		show    bool
+	}{
+		{`select(.type == "trade")`, `{"type":"trade","price":1}`, `{"type":"trade","price":1}`, true},
+		{`select(.type == "trade")`, `{"type":"heartbeat"}`, "", false},
+		{`.type == "trade"`, `{"type":"trade"}`, `{"type":"trade"}`, true},
+		{`.type == "trade"`, `{"type":"ticker"}`, "", false},
+		{`select(.type == "trade") | .price`, `{"type":"trade","price":12345678901234567890}`, `12345678901234567890`, true},
+		{`.type`, `{"type":"trade"}`, `trade`, true},
+		{`.missing`, `{"type":"trade"}`, "", false},
+		{`.items[]`, `{"items":[1,2]}`, "1\n2", true},
+		{`.data | {id}`, `{"data":{"id":1,"x":2}}`, `{"id":1}`, true},
+		{`.type`, `not json`, "", false},
+		{`.a.b`, `{"a":"string"}`, "", false},
+	}
+
+	for _, c := range cases {
+		f, err := New(c.expr)
+		if err != nil {
+			t.Fatalf("New(%q) error: %v", c.expr, err)
+		}
+
+		got, show := f.Apply([]byte(c.message))
+		if show != c.show || string(got) != c.want {
+			t.Errorf("Apply(%q, %q) = %q, %v, want %q, %v", c.expr, c.message, got, show, c.want, c.show)
+		}
+	}
+}
+
+func init() {
+	config.Flags = &config.Flag{}
+	logger.Init(io.Discard, nil)
+}
+
+func TestTruthy(t *testing.T) {
+	cases := []struct {
+		values []any
+		want   bool
+	}{
+		{nil, false},
+		{[]any{nil, false}, false},
+		{[]any{false, 0}, true},
+		{[]any{""}, true},
+		{[]any{true}, true},
+	}
+
+	for _, c := range cases {
+		if got := Truthy(c.values); got != c.want {
+			t.Errorf("Truthy(%v) = %v, want %v", c.values, got, c.want)
+		}
+	}
+}
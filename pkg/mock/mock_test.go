@@ -0,0 +1,205 @@
+package mock
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+	"github.com/gorilla/websocket"
+)
+
+func TestParse(t *testing.T) {
+	rules, err := Parse([]byte(`
+listen: ":9000/ws"
+echo: true
+on_connect:
+  - send: hi
+schedule:
+  - send: tick
+    every: 1s
+rules:
+  - match: '^a$'
+    reply: one
+  - json: '.type == "b"'
+    reply: [two, three]
+    delay: 10ms
+  - match: bye
+    close: 4000
+`))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	if rules.Listen != ":9000/ws" || !rules.Echo || len(rules.OnConnect) != 1 || rules.Schedule[0].Every != time.Second {
+		t.Errorf("Parse() = %+v", rules)
+	}
+
+	if got := rules.Rules[1].Reply; len(got) != 2 || got[0] != "two" || rules.Rules[1].Delay != 10*time.Millisecond {
+		t.Errorf("rule 2 = %+v", rules.Rules[1])
+	}
+
+	invalid := map[string]string{
+		"invalid regex":        "rules:\n  - match: '('\n    reply: x",
+		"invalid jq":           "rules:\n  - json: '.['\n    reply: x",
+		"no reply":             "rules:\n  - match: a",
+		"invalid template":     "rules:\n  - reply: '{{'",
+		"schedule no interval": "schedule:\n  - send: x",
+		"empty send":           "on_connect:\n  - delay: 1s",
+	}
+
+	for name, data := range invalid {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse() with %s should return error", name)
+		}
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	rules, err := Parse([]byte(`
+rules:
+  - match: '^hello (\w+)$'
+    reply: x
+  - json: '.type == "sub"'
+    reply: x
+  - match: 'sub'
+    json: '.channel'
+    reply: x
+`))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	cases := []struct {
+		rule    int
+		message string
+		want    bool
+	}{
+		{0, "hello bob", true},
+		{0, "hello", false},
+		{1, `{"type":"sub"}`, true},
+		{1, `{"type":"pub"}`, false},
+		{1, `not json`, false},
+		{2, `{"type":"sub","channel":"a"}`, true},
+		{2, `{"type":"sub"}`, false},
+	}
+
+	for _, c := range cases {
+		if _, got := rules.Rules[c.rule].matches([]byte(c.message)); got != c.want {
+			t.Errorf("rule %d matches(%s) = %v, want %v", c.rule+1, c.message, got, c.want)
+		}
+	}
+
+	if groups, _ := rules.Rules[0].matches([]byte("hello bob")); len(groups) != 2 || groups[1] != "bob" {
+		t.Errorf("groups = %q, want [hello bob, bob]", groups)
+	}
+}
+
+func TestServe(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true, PingInterval: time.Minute}
+
+	rules, err := Parse([]byte(`
+echo: true
+on_connect:
+  - send: 'welcome {{.Conn}}'
+rules:
+  - match: '^hello (\w+)$'
+    reply: 'hi {{index .Groups 1}} #{{.Count}}'
+  - json: '.type == "sub"'
+    reply:
+      - '{"ok":true,"channel":"{{.JSON.channel}}"}'
+      - 'done'
+  - match: '^zip$'
+    reply: zipped
+    gzip: true
+  - match: '^bye$'
+    close: 4001
+    reason: bye
+`))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	closef, err := New(rules).Serve(addr + "/ws")
+	if err != nil {
+		t.Fatalf("Serve() error: %v", err)
+	}
+	defer closef()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Dial() error: %v", err)
+	}
+	defer conn.Close()
+
+	expect := func(want string) {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		mt, got, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage() error: %v, want %s", err, want)
+		}
+		if mt == websocket.BinaryMessage {
+			r, _ := gzip.NewReader(bytes.NewReader(got))
+			got, _ = io.ReadAll(r)
+		}
+		if string(got) != want {
+			t.Errorf("received %q, want %q", got, want)
+		}
+	}
+
+	send := func(message string) {
+		conn.WriteMessage(websocket.TextMessage, []byte(message))
+	}
+
+	expect("welcome conn-1")
+
+	send("hello bob")
+	expect("hi bob #1")
+
+	send(`{"type":"sub","channel":"btc"}`)
+	expect(`{"ok":true,"channel":"btc"}`)
+	expect("done")
+
+	send("zip")
+	expect("zipped")
+
+	send("other")
+	expect("other")
+
+	send("bye")
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, 4001) || !strings.Contains(err.Error(), "bye") {
+		t.Errorf("ReadMessage() after bye = %v, want close 4001", err)
+	}
+}
+
+func TestCloseDisconnected(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true}
+	logger.Init(io.Discard, nil)
+
+	//e.g. a delayed close action firing after the peer disconnected.
+	c := &conn{client: ws.NewClient(), name: "conn-1", running: &sync.WaitGroup{}, done: make(chan struct{})}
+	c.stop()
+	c.close(websocket.CloseNormalClosure, "bye")
+	c.running.Wait()
+}
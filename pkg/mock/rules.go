@@ -0,0 +1,216 @@
+package mock
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"regexp"
+	"text/template"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/filter"
+	"github.com/akshaykhairmode/wscli/pkg/tmplfunc"
+	"gopkg.in/yaml.v3"
+)
+
+// Rules configure the mock server, see the serve section of the README.
+type Rules struct {
+	Listen    string  `yaml:"listen"`     //address to listen on, e.g. :8080/ws. --listen takes precedence.
+	Echo      bool    `yaml:"echo"`       //echo the messages which match no rule.
+	OnConnect []*Push `yaml:"on_connect"` //messages sent to every new connection.
+	Schedule  []*Push `yaml:"schedule"`   //messages sent to every connection at an interval.
+	Rules     []*Rule `yaml:"rules"`      //replies to received messages, the first matching rule is used.
+}
+
+// Push is a message sent without being asked for.
+type Push struct {
+	Send   string        `yaml:"send"`
+	Delay  time.Duration `yaml:"delay"` //on_connect: wait before sending. schedule: wait before the first message.
+	Every  time.Duration `yaml:"every"` //schedule only.
+	Binary bool          `yaml:"binary"`
+	Gzip   bool          `yaml:"gzip"` //gzip the message and send it as binary.
+
+	tmpl *template.Template
+}
+
+// Rule replies to the messages matching both Match and JSON, a rule without both matches every message.
+type Rule struct {
+	Match  string        `yaml:"match"` //regular expression, the submatches are available as .Groups.
+	JSON   string        `yaml:"json"`  //jq expression, matches if it returns a value other than null or false.
+	Reply  replies       `yaml:"reply"` //one or a list of messages.
+	Delay  time.Duration `yaml:"delay"`
+	Binary bool          `yaml:"binary"`
+	Gzip   bool          `yaml:"gzip"`
+	Close  int           `yaml:"close"` //close the connection with the code after replying.
+	Reason string        `yaml:"reason"`
+
+	regex  *regexp.Regexp
+	filter *filter.Filter
+	tmpls  []*template.Template
+}
+
+// replies is a single message or a list of messages.
+type replies []string
+
+func (r *replies) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*r = replies{node.Value}
+		return nil
+	}
+
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*r = list
+	return nil
+}
+
+// Data is available in the templates of the messages.
+type Data struct {
+	Conn    string   //name of the connection, e.g. conn-1.
+	Count   int      //number of messages received on the connection, including this one.
+	Message string   //received message.
+	JSON    any      //received message decoded, if it is JSON.
+	Groups  []string //submatches of the match expression, .Groups 0 is the whole match.
+}
+
+func Load(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading the rules : %w", err)
+	}
+
+	return Parse(data)
+}
+
+func Parse(data []byte) (*Rules, error) {
+	var r Rules
+	if err := yaml.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("error while unmarshalling the rules : %w", err)
+	}
+
+	if err := r.compile(); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func (r *Rules) compile() error {
+	for i, p := range r.OnConnect {
+		if err := p.compile(); err != nil {
+			return fmt.Errorf("on_connect %d : %w", i+1, err)
+		}
+	}
+
+	for i, p := range r.Schedule {
+		if p.Every <= 0 {
+			return fmt.Errorf("schedule %d : every must be greater than 0", i+1)
+		}
+		if err := p.compile(); err != nil {
+			return fmt.Errorf("schedule %d : %w", i+1, err)
+		}
+	}
+
+	for i, rule := range r.Rules {
+		if err := rule.compile(); err != nil {
+			return fmt.Errorf("rule %d : %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (p *Push) compile() error {
+	if p.Send == "" {
+		return fmt.Errorf("send is empty")
+	}
+
+	var err error
+	p.tmpl, err = parseTemplate(p.Send)
+	return err
+}
+
+func (r *Rule) compile() error {
+	var err error
+
+	if r.Match != "" {
+		if r.regex, err = regexp.Compile(r.Match); err != nil {
+			return fmt.Errorf("invalid match : %w", err)
+		}
+	}
+
+	if r.JSON != "" {
+		if r.filter, err = filter.New(r.JSON); err != nil {
+			return err
+		}
+	}
+
+	if len(r.Reply) == 0 && r.Close == 0 {
+		return fmt.Errorf("rule needs a reply or a close code")
+	}
+
+	for _, reply := range r.Reply {
+		tmpl, err := parseTemplate(reply)
+		if err != nil {
+			return err
+		}
+		r.tmpls = append(r.tmpls, tmpl)
+	}
+
+	return nil
+}
+
+// matches reports whether message matches the rule and returns the submatches of Match.
+func (r *Rule) matches(message []byte) ([]string, bool) {
+	var groups []string
+
+	if r.regex != nil {
+		m := r.regex.FindSubmatch(message)
+		if m == nil {
+			return nil, false
+		}
+		for _, g := range m {
+			groups = append(groups, string(g))
+		}
+	}
+
+	if r.filter != nil {
+		values, err := r.filter.Values(message)
+		if err != nil || !filter.Truthy(values) {
+			return nil, false
+		}
+	}
+
+	return groups, true
+}
+
+func parseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("message").Funcs(tmplfunc.FuncMap).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error while parsing the template : %s : %w", text, err)
+	}
+	return tmpl, nil
+}
+
+func render(tmpl *template.Template, data Data) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("error while executing the template : %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to write gzip data: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close gzip writer: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,259 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+	"github.com/gorilla/websocket"
+)
+
+const queueSize = 1000
+
+// Server replies to the accepted connections according to the rules.
+type Server struct {
+	rules *Rules
+
+	mux     sync.Mutex
+	conns   int
+	running sync.WaitGroup //goroutines of the accepted connections.
+}
+
+func New(rules *Rules) *Server {
+	return &Server{rules: rules}
+}
+
+// Serve accepts connections on addr, given like --listen (e.g. :8080/ws). The returned function
+// stops accepting, closes the connections and waits for their replies and pushes to stop.
+func (s *Server) Serve(addr string) (ws.CloseFunc, error) {
+	closef, err := ws.Listen(addr, s.accept)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		closef()
+		s.running.Wait()
+	}, nil
+}
+
+// conn is an accepted connection. Received messages are queued so that the replies
+// are sent in order without blocking the reading of the connection.
+type conn struct {
+	rules   *Rules
+	client  *ws.Client
+	name    string
+	running *sync.WaitGroup
+
+	count    int //messages received, only used by the worker.
+	queue    chan received
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+type received struct {
+	mt      int
+	message []byte
+}
+
+func (s *Server) accept(client *ws.Client) {
+	s.mux.Lock()
+	s.conns++
+	name := "conn-" + strconv.Itoa(s.conns)
+	s.mux.Unlock()
+
+	c := &conn{
+		rules:   s.rules,
+		client:  client,
+		name:    name,
+		running: &s.running,
+		queue:   make(chan received, queueSize),
+		done:    make(chan struct{}),
+	}
+
+	client.SetName(name)
+	client.OnMessage(c.enqueue)
+	client.OnClose(c.stop)
+
+	log.Println(ws.GreenColor("%s connected from %s", name, client.URL()))
+
+	c.spawn(c.worker)
+	c.spawn(c.onConnect)
+	for _, p := range s.rules.Schedule {
+		c.spawn(func() { c.schedule(p) })
+	}
+}
+
+// spawn runs f in a goroutine waited for by the close function of Serve.
+func (c *conn) spawn(f func()) {
+	c.running.Add(1)
+	go func() {
+		defer c.running.Done()
+		f()
+	}()
+}
+
+// stop stops the goroutines of the connection once it is closed.
+func (c *conn) stop() {
+	c.stopOnce.Do(func() {
+		close(c.done)
+		log.Printf("%s disconnected", c.name)
+	})
+}
+
+func (c *conn) enqueue(mt int, message []byte) {
+	select {
+	case c.queue <- received{mt, message}:
+	default:
+		logger.Debug().Msgf("%s queue is full, message dropped", c.name)
+	}
+}
+
+func (c *conn) worker() {
+	for {
+		select {
+		case <-c.done:
+			return
+		case r := <-c.queue:
+			c.count++
+			c.reply(r)
+		}
+	}
+}
+
+// reply sends the replies of the first rule matching the message, or echoes it.
+func (c *conn) reply(r received) {
+	for _, rule := range c.rules.Rules {
+		groups, ok := rule.matches(r.message)
+		if !ok {
+			continue
+		}
+
+		data := Data{Conn: c.name, Count: c.count, Message: string(r.message), JSON: decodeJSON(r.message), Groups: groups}
+
+		if !c.sleep(rule.Delay) {
+			return
+		}
+
+		for _, tmpl := range rule.tmpls {
+			c.send(tmpl, data, rule.Binary, rule.Gzip)
+		}
+
+		if rule.Close != 0 {
+			c.close(rule.Close, rule.Reason)
+		}
+
+		return
+	}
+
+	if !c.rules.Echo {
+		return
+	}
+
+	if err := c.client.Send(r.mt, r.message); err != nil {
+		logger.Debug().Err(err).Msgf("%s : error while sending", c.name)
+		return
+	}
+
+	log.Println(ws.BlueColor("[%s] » %s", c.name, r.message))
+}
+
+func (c *conn) onConnect() {
+	for _, p := range c.rules.OnConnect {
+		if !c.sleep(p.Delay) {
+			return
+		}
+		c.send(p.tmpl, Data{Conn: c.name}, p.Binary, p.Gzip)
+	}
+}
+
+func (c *conn) schedule(p *Push) {
+	if !c.sleep(p.Delay) {
+		return
+	}
+
+	ticker := time.NewTicker(p.Every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.send(p.tmpl, Data{Conn: c.name}, p.Binary, p.Gzip)
+		}
+	}
+}
+
+func (c *conn) send(tmpl *template.Template, data Data, binary, gz bool) {
+	message, err := render(tmpl, data)
+	if err != nil {
+		log.Println(ws.RedColor("%s : %s", c.name, err))
+		return
+	}
+
+	mt, payload := websocket.TextMessage, message
+	if binary {
+		mt = websocket.BinaryMessage
+	}
+	if gz {
+		if payload, err = gzipBytes(message); err != nil {
+			log.Println(err)
+			return
+		}
+		mt = websocket.BinaryMessage
+	}
+
+	if err := c.client.Send(mt, payload); err != nil {
+		logger.Debug().Err(err).Msgf("%s : error while sending", c.name)
+		return
+	}
+
+	log.Println(ws.BlueColor("[%s] » %s", c.name, message))
+}
+
+// close sends a close message, the connection is closed if the client does not reply to it.
+func (c *conn) close(code int, reason string) {
+	if err := c.client.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason)); err != nil {
+		logger.Debug().Err(err).Msg("write close error")
+	}
+
+	c.spawn(func() {
+		if c.sleep(time.Second) {
+			c.client.Close()
+			c.stop()
+		}
+	})
+}
+
+// sleep waits for d and reports false if the connection was closed meanwhile.
+func (c *conn) sleep(d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+
+	select {
+	case <-c.done:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// decodeJSON returns the decoded message, nil if it is not JSON.
+func decodeJSON(message []byte) any {
+	dec := json.NewDecoder(bytes.NewReader(message))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil
+	}
+	return v
+}
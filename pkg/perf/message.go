@@ -5,15 +5,13 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"math/rand"
 	"os"
 	"sync"
-	"sync/atomic"
 	"text/template"
 	"time"
 
 	"github.com/akshaykhairmode/wscli/pkg/logger"
-	"github.com/google/uuid"
+	"github.com/akshaykhairmode/wscli/pkg/tmplfunc"
 )
 
 type messageGetter interface {
@@ -121,11 +119,9 @@ type DefaultMessageGetter struct {
 	pool *sync.Pool
 }
 
-var uniqueSequenceMap = &sync.Map{}
-
 func NewDefaultMessageGetter(msg string) (messageGetter, error) {
 
-	tmpl := template.New("parse").Funcs(funcMap)
+	tmpl := template.New("parse").Funcs(tmplfunc.FuncMap)
 	if err := parseTemplate(tmpl, msg); err != nil {
 		return nil, fmt.Errorf("error while parsing the template : %s : %w", msg, err)
 	}
@@ -166,74 +162,6 @@ func (m *DefaultMessageGetter) GetTemplateString() string {
 	return string(m.msg)
 }
 
-var funcMap = template.FuncMap{
-	"RandomNum":   randomInt,
-	"RandomUUID":  randomUUID,
-	"RandomAN":    randomAlphaNumeric,
-	"UniqSeq":     getUniqueSequence,
-	"Array":       array,
-	"RandomArray": randomArray,
-}
-
-var arrayCounter = &atomic.Uint64{}
-
-func array(elems ...string) string {
-	return elems[arrayCounter.Add(1)%uint64(len(elems))]
-}
-
-func randomArray(elems ...string) string {
-	return elems[rand.Intn(len(elems))]
-}
-
-func getUniqueSequence(group string, start ...uint64) uint64 {
-
-	val, _ := uniqueSequenceMap.LoadOrStore(group, getUint64Counter(start...))
-	tc := val.(*atomic.Uint64)
-
-	newVal := tc.Add(1)
-
-	return newVal - 1
-}
-
-func getUint64Counter(start ...uint64) *atomic.Uint64 {
-	c := &atomic.Uint64{}
-	if len(start) <= 0 || start[0] <= 0 {
-		return c
-	}
-
-	c.Store(start[0])
-	return c
-}
-
-const alphaNumericChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-
-func randomAlphaNumeric(length ...int) string {
-	l := 10
-	if len(length) > 0 {
-		l = length[0]
-	}
-
-	b := make([]byte, l)
-	for i := range b {
-		b[i] = alphaNumericChars[rand.Intn(len(alphaNumericChars))]
-	}
-
-	return string(b)
-}
-
-func randomInt(max ...int) int {
-	if len(max) <= 0 {
-		return rand.Intn(10000)
-	}
-
-	return rand.Intn(max[0])
-}
-
-func randomUUID() string {
-	guid := uuid.New()
-	return guid.String()
-}
-
 func parseTemplate(tmpl *template.Template, str string) error {
 
 	if str == "" {
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
 	"github.com/rcrowley/go-metrics"
 )
 
@@ -20,6 +21,8 @@ type Metrics struct {
 	totalSentMessages     metrics.Counter
 	totalReceivedMessages metrics.Counter
 	failedMessages        metrics.Counter
+	sentBytes             metrics.Counter
+	receivedBytes         metrics.Counter
 
 	connectTime metrics.Timer
 	messageTime metrics.Timer
@@ -80,6 +83,8 @@ func NewMetrics(totalConns int64, out string) *Metrics {
 		totalSentMessages:     metrics.NewCounter(),
 		totalReceivedMessages: metrics.NewCounter(),
 		failedMessages:        metrics.NewCounter(),
+		sentBytes:             metrics.NewCounter(),
+		receivedBytes:         metrics.NewCounter(),
 		connectTime:           metrics.NewTimer(),
 		messageTime:           metrics.NewTimer(),
 		totalConns:            totalConns,
@@ -97,6 +102,8 @@ func NewMetrics(totalConns int64, out string) *Metrics {
 	metrics.MustRegister("total_sent", m.totalSentMessages)
 	metrics.MustRegister("total_received", m.totalReceivedMessages)
 	metrics.MustRegister("total_failed", m.failedMessages)
+	metrics.MustRegister("sent_bytes", m.sentBytes)
+	metrics.MustRegister("received_bytes", m.receivedBytes)
 	metrics.MustRegister("connection_time", m.connectTime)
 	metrics.MustRegister("message_time", m.messageTime)
 
@@ -187,6 +194,7 @@ func (m *Metrics) getTable() map[string]string {
 
 	connectTime := m.connectTime.Snapshot()
 	messageTime := m.messageTime.Snapshot()
+	wireRead, wireWritten := ws.WireStats()
 
 	for _, val := range headings {
 
@@ -203,6 +211,19 @@ func (m *Metrics) getTable() map[string]string {
 			final[val] = intToString(m.totalReceivedMessages.Count())
 		case TotalFailedMessages:
 			final[val] = intToString(m.failedMessages.Count())
+		case SentBytes:
+			final[val] = formatBytes(m.sentBytes.Count())
+		case SentWireBytes:
+			final[val] = formatBytes(wireWritten)
+		case ReceivedBytes:
+			final[val] = formatBytes(m.receivedBytes.Count())
+		case ReceivedWireBytes:
+			final[val] = formatBytes(wireRead)
+		case CompressedConnections:
+			final[val] = "off"
+			if config.Flags.Compress {
+				final[val] = calculatePercentage(ws.CompressedConns(), m.totalConns)
+			}
 		case ConnectionMeanTime:
 			final[val] = durToString(connectTime.Mean())
 		case ConnectionP95Time:
@@ -234,6 +255,22 @@ func durToString(f float64) string {
 	return time.Duration(f).Round(time.Millisecond).String()
 }
 
+// formatBytes returns n in the largest binary unit which keeps it at or above 1, e.g. 1.5 KiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
 func calculatePercentage(value, total int64) string {
 	if total == 0 {
 		return "0.00%"
@@ -269,6 +306,14 @@ func (m *Metrics) IncrReceivedMessages() {
 	m.totalReceivedMessages.Inc(1)
 }
 
+func (m *Metrics) AddSentBytes(n int) {
+	m.sentBytes.Inc(int64(n))
+}
+
+func (m *Metrics) AddReceivedBytes(n int) {
+	m.receivedBytes.Inc(int64(n))
+}
+
 func (m *Metrics) SetAvgConnectTime(dur time.Duration) {
 	m.connectTime.Update(dur)
 }
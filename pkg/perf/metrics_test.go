@@ -40,6 +40,21 @@ func TestIntToString(t *testing.T) {
 	}
 }
 
+func TestFormatBytes(t *testing.T) {
+	cases := map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1024:            "1.0 KiB",
+		1536:            "1.5 KiB",
+		5 * 1024 * 1024: "5.0 MiB",
+	}
+	for input, want := range cases {
+		if got := formatBytes(input); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", input, got, want)
+		}
+	}
+}
+
 func TestDurToString(t *testing.T) {
 	cases := []struct {
 		input float64
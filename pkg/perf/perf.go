@@ -147,6 +147,7 @@ func (g *Generator) messgeReceiver(conn *websocket.Conn, wg *sync.WaitGroup, wai
 		}
 
 		g.metric.IncrReceivedMessages()
+		g.metric.AddReceivedBytes(len(data))
 	}
 }
 
@@ -156,7 +157,7 @@ func (g *Generator) processConnection(wg *sync.WaitGroup, isSlowReader bool) {
 
 	//connect
 	now := time.Now()
-	conn, closef, _, err := ws.Connect()
+	conn, closef, err := ws.Connect(config.Flags.ConnectURL)
 	if err != nil {
 		logger.Error().Err(err).Msg("error while connecting")
 		return
@@ -191,6 +192,8 @@ func (g *Generator) processConnection(wg *sync.WaitGroup, isSlowReader bool) {
 				logger.Error().Err(err).Msg("error while sending the auth message")
 				return
 			}
+
+			g.metric.AddSentBytes(len(msg))
 		}()
 
 	}
@@ -221,6 +224,7 @@ func (g *Generator) processConnection(wg *sync.WaitGroup, isSlowReader bool) {
 
 		g.metric.SetAvgMessageTime(time.Since(now))
 		g.metric.IncrSentMessages()
+		g.metric.AddSentBytes(len(msg))
 		seqCounter++
 	}
 
@@ -20,6 +20,12 @@ const (
 	TotalReceivedMessages = "M-Received"
 	TotalFailedMessages   = "M-Failed"
 
+	SentBytes             = "B-Sent"
+	SentWireBytes         = "B-Sent-Wire"
+	ReceivedBytes         = "B-Received"
+	ReceivedWireBytes     = "B-Received-Wire"
+	CompressedConnections = "Compressed"
+
 	ConnectionMeanTime = "C-Mean"
 	ConnectionP95Time  = "C-P95"
 	ConnectionP99Time  = "C-P99"
@@ -40,6 +46,12 @@ var headings = []string{
 	TotalReceivedMessages,
 	TotalFailedMessages,
 
+	SentBytes,
+	SentWireBytes,
+	ReceivedBytes,
+	ReceivedWireBytes,
+	CompressedConnections,
+
 	ConnectionMeanTime,
 	ConnectionP95Time,
 	ConnectionP99Time,
@@ -342,10 +354,11 @@ func renderConnections(data map[string]string) string {
 	}
 
 	return fmt.Sprintf(
-		"%s   %s\n%s  %s\n%s %s\n%s",
+		"%s   %s\n%s  %s\n%s %s\n%s %s\n%s",
 		tag("Total:"), data[TotalConnections],
 		tag("Active:"), fmt.Sprintf("[%s]%s[white]", colGood, data[ActiveConnections]),
 		tag("Dropped:"), fmt.Sprintf("[%s]%s[white]", droppedColor, data[DroppedConnections]),
+		tag("Deflate:"), data[CompressedConnections],
 		progressBar(active, total, progressBarWidth),
 	)
 }
@@ -372,10 +385,12 @@ func (tv *Tview) renderMessages(data map[string]string) string {
 	}
 
 	return fmt.Sprintf(
-		"%s     %s  [%s]%s[white]\n%s %s  [%s]%s[white]\n%s   [%s]%s[white]  [%s]%s[white]",
+		"%s     %s  [%s]%s[white]\n%s %s  [%s]%s[white]\n%s   [%s]%s[white]  [%s]%s[white]\n%s %s  [%s]wire %s[white]\n%s  %s  [%s]wire %s[white]",
 		tag("Sent:"), formatInt(sent), colInfo, rateSent,
 		tag("Received:"), formatInt(recv), colInfo, rateRecv,
 		tag("Failed:"), failedColor, formatInt(failed), failedColor, rateFailed,
+		tag("Bytes Out:"), data[SentBytes], colMuted, data[SentWireBytes],
+		tag("Bytes In:"), data[ReceivedBytes], colMuted, data[ReceivedWireBytes],
 	)
 }
 
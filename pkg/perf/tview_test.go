@@ -89,6 +89,8 @@ func TestGetTable(t *testing.T) {
 		totalSentMessages:     metrics.NewCounter(),
 		totalReceivedMessages: metrics.NewCounter(),
 		failedMessages:        metrics.NewCounter(),
+		sentBytes:             metrics.NewCounter(),
+		receivedBytes:         metrics.NewCounter(),
 		connectTime:           metrics.NewTimer(),
 		messageTime:           metrics.NewTimer(),
 		totalConns:            100,
@@ -97,6 +99,7 @@ func TestGetTable(t *testing.T) {
 	}
 	m.activeConnections.Inc(25)
 	m.totalSentMessages.Inc(50)
+	m.sentBytes.Inc(2048)
 
 	data := m.getTable()
 	for _, heading := range headings {
@@ -108,6 +111,10 @@ func TestGetTable(t *testing.T) {
 	if got := data[TotalConnections]; got != "100" {
 		t.Errorf("TotalConnections = %q, want 100", got)
 	}
+
+	if got := data[SentBytes]; got != "2.0 KiB" {
+		t.Errorf("SentBytes = %q, want 2.0 KiB", got)
+	}
 	if got := data[ActiveConnections]; !strings.Contains(got, "25") {
 		t.Errorf("ActiveConnections = %q, want it to contain 25", got)
 	}
@@ -0,0 +1,171 @@
+package processer
+
+import (
+	"log"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/global"
+	"github.com/akshaykhairmode/wscli/pkg/protocol"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+)
+
+const defaultConnName = "default"
+
+// client returns the active connection.
+func (i *Interactive) client() *ws.Client {
+	i.mux.RLock()
+	defer i.mux.RUnlock()
+	return i.clients[i.active]
+}
+
+// current returns the active connection, or logs that there is none, e.g. with --listen
+// before a client connected.
+func (i *Interactive) current() *ws.Client {
+	client := i.client()
+	if client == nil {
+		log.Println("no connection, waiting for a client to connect")
+	}
+	return client
+}
+
+func (i *Interactive) open(args string) {
+	name, connectURL, _ := strings.Cut(args, " ")
+	connectURL = strings.TrimSpace(connectURL)
+	if name == "" || connectURL == "" {
+		log.Println("invalid open command, usage /open <name> <url>")
+		return
+	}
+
+	i.mux.RLock()
+	_, exists := i.clients[name]
+	i.mux.RUnlock()
+
+	if exists {
+		log.Printf("connection %s already exists", name)
+		return
+	}
+
+	client := ws.NewClient()
+	client.SetName(name)
+	client.OnClose(func() { i.removeClient(name) })
+
+	if config.Flags.Protocol.Name != "" {
+		if _, err := protocol.Attach(config.Flags.Protocol.Name, client); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+
+	if err := client.Connect(connectURL); err != nil {
+		log.Printf("connect err : %s", err)
+		return
+	}
+
+	i.mux.Lock()
+	i.clients[name] = client
+	//tag the messages of every connection once there is more than one.
+	for n, c := range i.clients {
+		c.SetName(n)
+	}
+	i.mux.Unlock()
+
+	log.Println(ws.GreenColor("Connected %s to %s, use /use %s to send messages to it", name, connectURL, name))
+	i.setPrompt()
+}
+
+func (i *Interactive) use(name string) {
+	i.mux.Lock()
+	_, exists := i.clients[name]
+	if exists {
+		i.active = name
+	}
+	i.mux.Unlock()
+
+	if !exists {
+		log.Printf("connection %s does not exist", name)
+		return
+	}
+
+	i.setPrompt()
+}
+
+func (i *Interactive) list(string) {
+	i.mux.RLock()
+	defer i.mux.RUnlock()
+
+	for _, name := range slices.Sorted(maps.Keys(i.clients)) {
+		marker := " "
+		if name == i.active {
+			marker = "*"
+		}
+		log.Printf("%s %-15s %s", marker, name, i.clients[name].URL())
+	}
+}
+
+func (i *Interactive) closeConn(name string) {
+	i.mux.RLock()
+	client, exists := i.clients[name]
+	last := len(i.clients) == 1
+	i.mux.RUnlock()
+
+	if !exists {
+		log.Printf("connection %s does not exist", name)
+		return
+	}
+
+	if last && i.listen == "" {
+		log.Println("cannot close the last connection, use /exit instead")
+		return
+	}
+
+	client.Close()
+	i.removeClient(name)
+}
+
+// Close closes every connection of the session, including the ones opened with /open.
+func (i *Interactive) Close() {
+	i.mux.RLock()
+	clients := slices.Collect(maps.Values(i.clients))
+	i.mux.RUnlock()
+
+	for _, c := range clients {
+		c.Close()
+	}
+}
+
+// removeClient forgets a closed connection and stops the application when none is left.
+func (i *Interactive) removeClient(name string) {
+	i.mux.Lock()
+	delete(i.clients, name)
+	left := len(i.clients)
+
+	if i.active == name && left > 0 {
+		i.active = slices.Sorted(maps.Keys(i.clients))[0]
+	}
+
+	//accepted connections are always tagged.
+	if left == 1 && i.listen == "" {
+		for _, c := range i.clients {
+			c.SetName("")
+		}
+	}
+	active := i.active
+	i.mux.Unlock()
+
+	if left == 0 && i.listen != "" {
+		log.Printf("connection %s closed, waiting for connections", name)
+		i.setPrompt()
+		return
+	}
+
+	if left == 0 {
+		global.Stop()
+		return
+	}
+
+	log.Printf("connection %s closed, active connection is %s", name, active)
+	i.setPrompt()
+}
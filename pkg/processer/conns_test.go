@@ -0,0 +1,98 @@
+package processer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+)
+
+func TestUseAndRemoveClient(t *testing.T) {
+	i := New(ws.NewClient(), nil)
+
+	feed := ws.NewClient()
+	chat := ws.NewClient()
+	i.clients["feed"] = feed
+	i.clients["chat"] = chat
+
+	i.use("missing")
+	if i.active != defaultConnName {
+		t.Errorf("use() of missing connection changed active to %q", i.active)
+	}
+
+	i.use("feed")
+	if i.client() != feed {
+		t.Errorf("client() after use(feed) is not the feed connection")
+	}
+
+	i.removeClient("feed")
+	if _, ok := i.clients["feed"]; ok {
+		t.Error("removeClient() did not remove the connection")
+	}
+	if i.active != "chat" {
+		t.Errorf("active after removing the active connection = %q, want %q", i.active, "chat")
+	}
+
+	chat.SetName("chat")
+	i.removeClient(defaultConnName)
+	if chat.Name() != "" {
+		t.Errorf("last connection name = %q, want it to be untagged", chat.Name())
+	}
+}
+
+func TestCloseConnLast(t *testing.T) {
+	client := ws.NewClient()
+	i := New(client, nil)
+
+	i.closeConn(defaultConnName)
+	if i.client() != client {
+		t.Error("closeConn() closed the last connection")
+	}
+}
+
+func TestListenerRemoveClient(t *testing.T) {
+	i := NewListener(":0", nil)
+
+	first, second := ws.NewClient(), ws.NewClient()
+	i.accept(first)
+	i.accept(second)
+
+	if i.active != "client-2" || first.Name() != "client-1" {
+		t.Errorf("active = %q, first name = %q, want client-2 and client-1", i.active, first.Name())
+	}
+
+	i.removeClient("client-2")
+	if i.active != "client-1" || first.Name() != "client-1" {
+		t.Errorf("after removing client-2 active = %q, name = %q, want the tagged client-1", i.active, first.Name())
+	}
+
+	i.closeConn("client-1")
+	if len(i.clients) != 0 {
+		t.Errorf("closeConn() of the last accepted connection left %d connections", len(i.clients))
+	}
+}
+
+func TestCloseOpened(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true, PingInterval: time.Minute}
+
+	srv, _ := execServer(t, false)
+	client := execClient(t, srv)
+	defer client.Close()
+
+	i := New(client, nil)
+	i.open("feed ws" + strings.TrimPrefix(srv.URL, "http"))
+
+	feed := i.clients["feed"]
+	if feed == nil || feed.Conn() == nil {
+		t.Fatal("open() did not connect the feed connection")
+	}
+
+	i.Close()
+	if client.Conn() != nil || feed.Conn() != nil {
+		t.Error("Close() left a connection open")
+	}
+}
@@ -0,0 +1,172 @@
+package processer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+	"github.com/gorilla/websocket"
+)
+
+// execGrace is how long the command may take to exit after its stdin is closed, before it is killed.
+const execGrace = 5 * time.Second
+
+// maxExecLine is the longest line of the command output sent as one message.
+const maxExecLine = 1024 * 1024
+
+// Exec runs command with every line of its stdout sent to client and every received message written
+// to its stdin, one per line. With --exec-raw stdout is sent in binary chunks and messages are written
+// as is. It returns the exit code of the command once it exited, or once the connection is closed:
+// its stdin is then closed and it is killed if it does not exit within execGrace. Messages received
+// after the command exited are dropped.
+func Exec(client *ws.Client, command string) (int, error) {
+	cmd := shellCommand(command)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return 0, fmt.Errorf("error while creating the stdin pipe : %w", err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, fmt.Errorf("error while creating the stdout pipe : %w", err)
+	}
+
+	closed := make(chan struct{})
+	var closeOnce sync.Once
+	client.OnClose(func() { closeOnce.Do(func() { close(closed) }) })
+
+	//messages received once stdin is closed are dropped, the listener cannot be removed.
+	var stdinClosed atomic.Bool
+	closeStdin := func() {
+		if stdinClosed.Swap(true) {
+			return
+		}
+		if err := stdin.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+			logger.Debug().Err(err).Msg("error while closing the command stdin")
+		}
+	}
+
+	client.OnMessage(func(_ int, message []byte) {
+		if stdinClosed.Load() {
+			return
+		}
+
+		if !config.Flags.ExecRaw {
+			message = append(slices.Clip(message), '\n')
+		}
+
+		if _, err := stdin.Write(message); err != nil {
+			logger.Debug().Err(err).Msg("error while writing to the command")
+		}
+	})
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("error while starting the command : %w", err)
+	}
+
+	i := New(client, nil)
+	i.execute(client)
+	client.OnReconnect(func() { i.execute(client) })
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if config.Flags.ExecRaw {
+			sendChunks(client, stdout)
+		} else {
+			sendLines(i, stdout)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-closed:
+		closeStdin()
+
+		select {
+		case <-done:
+		case <-time.After(execGrace):
+			log.Printf("command did not exit within %s after the connection closed, killing it", execGrace)
+			if err := cmd.Process.Kill(); err != nil {
+				logger.Debug().Err(err).Msg("error while killing the command")
+			}
+			<-done
+		}
+	}
+
+	err = cmd.Wait()
+	closeStdin()
+
+	return exitCode(err)
+}
+
+// sendLines sends every line like a typed one, so that slash and protocol commands can be used.
+func sendLines(i *Interactive, stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxExecLine)
+
+	for scanner.Scan() {
+		i.handle(scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Printf("error while reading the command output : %s", err)
+	}
+}
+
+func sendChunks(client *ws.Client, stdout io.Reader) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := stdout.Read(buf)
+		if n > 0 {
+			if err := client.Write(websocket.BinaryMessage, buf[:n]); err != nil {
+				log.Printf("write error : %s", err)
+			}
+		}
+
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("error while reading the command output : %s", err)
+			}
+			return
+		}
+	}
+}
+
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// exitCode returns the exit code of a finished command, 1 if it was killed.
+func exitCode(err error) (int, error) {
+	if err == nil {
+		return 0, nil
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 0, fmt.Errorf("error while waiting for the command : %w", err)
+	}
+
+	if code := exitErr.ExitCode(); code > 0 {
+		return code, nil
+	}
+	return 1, nil
+}
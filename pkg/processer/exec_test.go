@@ -0,0 +1,145 @@
+package processer
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+	"github.com/gorilla/websocket"
+)
+
+// execServer echoes the received messages, or closes the connection after the first one with closeFirst.
+func execServer(t *testing.T, closeFirst bool) (*httptest.Server, chan string) {
+	received := make(chan string, 10)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			mt, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- string(message)
+
+			if closeFirst {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				conn.ReadMessage()
+				return
+			}
+			conn.WriteMessage(mt, message)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, received
+}
+
+func execClient(t *testing.T, srv *httptest.Server) *ws.Client {
+	logger.Init(io.Discard, nil)
+
+	client := ws.NewClient()
+	if err := client.Connect("ws" + strings.TrimPrefix(srv.URL, "http")); err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+
+	return client
+}
+
+func TestExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the command uses sh")
+	}
+
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true, PingInterval: time.Minute}
+
+	srv, received := execServer(t, false)
+	client := execClient(t, srv)
+	defer client.Close()
+
+	code, err := Exec(client, `echo hello; read line; echo "got $line"; exit 3`)
+	if err != nil {
+		t.Fatalf("Exec() error: %v", err)
+	}
+
+	if code != 3 {
+		t.Errorf("Exec() = %d, want the exit code of the command", code)
+	}
+
+	for _, want := range []string{"hello", "got hello"} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Errorf("server received %q, want %q", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for %q", want)
+		}
+	}
+}
+
+func TestExecConnectionClosed(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the command uses sh")
+	}
+
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true, PingInterval: time.Minute}
+
+	srv, _ := execServer(t, true)
+	client := execClient(t, srv)
+	defer client.Close()
+
+	result := make(chan int, 1)
+	go func() {
+		//cat exits when its stdin is closed after the connection closed.
+		code, err := Exec(client, "echo hi; cat")
+		if err != nil {
+			t.Errorf("Exec() error: %v", err)
+		}
+		result <- code
+	}()
+
+	select {
+	case code := <-result:
+		if code != 0 {
+			t.Errorf("Exec() = %d, want 0", code)
+		}
+	case <-time.After(execGrace):
+		t.Fatal("Exec() did not return after the connection closed")
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	if code, err := exitCode(nil); code != 0 || err != nil {
+		t.Errorf("exitCode(nil) = %d, %v, want 0", code, err)
+	}
+
+	if _, err := exitCode(errors.New("wait error")); err == nil {
+		t.Error("exitCode() of a non exit error should return error")
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	if code, err := exitCode(exec.Command("sh", "-c", "exit 7").Run()); code != 7 || err != nil {
+		t.Errorf("exitCode() = %d, %v, want 7", code, err)
+	}
+}
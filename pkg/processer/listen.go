@@ -0,0 +1,74 @@
+package processer
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/akshaykhairmode/wscli/pkg/terminal"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+)
+
+// NewListener returns the session of --listen, connections are added when they are accepted.
+func NewListener(addr string, term *terminal.Term) *Interactive {
+	return &Interactive{
+		clients: map[string]*ws.Client{},
+		term:    term,
+		vars:    map[string]string{},
+		listen:  addr,
+		first:   make(chan struct{}),
+	}
+}
+
+// Listen accepts connections on the --listen address and processes the typed lines.
+// Lines are sent to the most recently accepted connection, /use selects another one.
+// Without terminal the piped lines are processed once the first connection is accepted.
+func (i *Interactive) Listen() (ws.CloseFunc, error) {
+	closef, err := ws.Listen(i.listen, i.accept)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println(ws.GreenColor("Listening on %s", i.listen))
+
+	if i.term == nil {
+		go catchSignals(i.Close, nil)
+		go i.readPipe()
+		return closef, nil
+	}
+
+	i.setPrompt()
+	i.term.OnMessage(i.handle)
+
+	return closef, nil
+}
+
+// accept adds an accepted connection as client-N and makes it the active one.
+func (i *Interactive) accept(client *ws.Client) {
+	i.mux.Lock()
+	i.accepted++
+	name := "client-" + strconv.Itoa(i.accepted)
+	i.clients[name] = client
+	i.active = name
+	i.mux.Unlock()
+
+	client.SetName(name)
+	client.OnClose(func() { i.removeClient(name) })
+
+	log.Println(ws.GreenColor("%s connected from %s, messages are sent to it", name, client.URL()))
+	i.setPrompt()
+
+	i.execute(client)
+
+	i.firstOnce.Do(func() { close(i.first) })
+}
+
+func (i *Interactive) readPipe() {
+	<-i.first
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		i.handle(scanner.Text())
+	}
+}
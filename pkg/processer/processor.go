@@ -9,11 +9,13 @@ import (
 	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/akshaykhairmode/wscli/pkg/config"
 	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/akshaykhairmode/wscli/pkg/protocol"
 	"github.com/akshaykhairmode/wscli/pkg/terminal"
 	"github.com/akshaykhairmode/wscli/pkg/ws"
 
@@ -21,64 +23,268 @@ import (
 )
 
 type Interactive struct {
-	conn *websocket.Conn
-	term *terminal.Term
+	mux     sync.RWMutex
+	clients map[string]*ws.Client
+	active  string
+	term    *terminal.Term
+	vars    map[string]string //template variables set with /set.
+
+	listen    string        //--listen address, connections are accepted instead of dialed.
+	accepted  int           //number of accepted connections, used to name them.
+	first     chan struct{} //closed when the first connection is accepted.
+	firstOnce sync.Once
 }
 
-func New(conn *websocket.Conn, term *terminal.Term) *Interactive {
+type command struct {
+	name    string
+	usage   string
+	help    string
+	handler func(args string)
+}
+
+func New(client *ws.Client, term *terminal.Term) *Interactive {
 	return &Interactive{
-		conn: conn,
-		term: term,
+		clients: map[string]*ws.Client{defaultConnName: client},
+		active:  defaultConnName,
+		term:    term,
+		vars:    map[string]string{},
 	}
 }
 
-func ProcessAsCmd(conn *websocket.Conn) {
-	for _, cmd := range config.Flags.Execute {
-		ws.WriteToServer(conn, websocket.TextMessage, []byte(cmd))
-	}
+func ProcessAsCmd(client *ws.Client) {
+	i := New(client, nil)
+	defer i.Close()
+
+	i.execute(client)
+	client.OnReconnect(func() { i.execute(client) })
 
 	defer func() {
 		<-time.After(config.Flags.Wait)
 	}()
 
 	if config.Flags.IsSTDin {
-		go catchSignals(conn, nil)
+		go catchSignals(i.Close, nil)
 		scanner := bufio.NewScanner(os.Stdin)
 		for scanner.Scan() {
-			ws.WriteToServer(conn, websocket.TextMessage, scanner.Bytes())
+			i.handle(scanner.Text())
 		}
 	}
 }
 
 func (i *Interactive) Process() {
 
+	client := i.client()
+	i.execute(client)
+	client.OnReconnect(func() { i.execute(client) })
+	client.OnClose(func() { i.removeClient(defaultConnName) })
+
+	for _, cmd := range i.protocolCommands() {
+		terminal.AddCompletions(cmd.name)
+	}
+
+	i.setPrompt()
+
+	i.term.OnMessage(i.handle)
+
+}
+
+func (i *Interactive) commands() []command {
+	return append(i.protocolCommands(), i.builtinCommands()...)
+}
+
+// protocolCommands returns the slash commands of the protocol of the active connection.
+func (i *Interactive) protocolCommands() []command {
+	client := i.client()
+	if client == nil {
+		return nil
+	}
+
+	adapter, ok := protocol.Of(client)
+	if !ok {
+		return nil
+	}
+
+	var cmds []command
+	for _, cmd := range adapter.Commands() {
+		handler := cmd.Handler
+		cmds = append(cmds, command{cmd.Name, cmd.Usage, cmd.Help, func(args string) {
+			if err := handler(args); err != nil {
+				log.Println(err)
+			}
+		}})
+	}
+
+	return cmds
+}
+
+func (i *Interactive) builtinCommands() []command {
+	return []command{
+		{"/connect", "/connect <url>", "Close the current connection and connect to a new url.", i.connect},
+		{"/wait", "/wait <duration>", "Pause before processing the next input (1s, 500ms).", wait},
+		{"/print", "/print <text>", "Print text locally without sending it.", printText},
+		{"/filter", "/filter [jq expression]", "Show only received messages matching the filter, without expression removes the filter.", setFilter},
+		{"/open", "/open <name> <url>", "Open an additional named connection.", i.open},
+		{"/use", "/use <name>", "Send the following messages and commands to the named connection.", i.use},
+		{"/list", "/list", "List the open connections.", i.list},
+		{"/closeconn", "/closeconn <name>", "Close the named connection.", i.closeConn},
+		{"/set", "/set [name] [value]", "Set a variable used as {{.name}} with --template, without value removes it, without arguments lists them.", i.set},
+		{"/flags", "/flags", "Show loaded flags.", func(string) { log.Println(config.Flags.String()) }},
+		{"/ping", "/ping [data]", "Send a ping message.", i.pingPongHandler(websocket.PingMessage)},
+		{"/pong", "/pong [data]", "Send a pong message.", i.pingPongHandler(websocket.PongMessage)},
+		{"/close", "/close <code> <reason>", "Send a close message.", i.closeHandler},
+		{"/bfile", "/bfile <file_path>", "Send a file as a binary message. Max size: 50MB.", i.sendBinaryFile},
+		{"/help", "/help", "Show the available commands.", i.help},
+		{"/exit", "/exit", "Exit the application.", nil},
+	}
+}
+
+// execute processes the messages passed with -x, messages are sent to client.
+func (i *Interactive) execute(client *ws.Client) {
 	for _, cmd := range config.Flags.Execute {
-		ws.WriteToServer(i.conn, websocket.TextMessage, []byte(cmd))
-	}
-
-	i.term.AppendPrompt(fmt.Sprintf("(%s)»", truncateString(config.Flags.ConnectURL, 25)))
-
-	i.term.OnMessage(func(line string) {
-		switch {
-		case shouldProcessCommand(line, "/flags"):
-			log.Println(config.Flags.String())
-		case shouldProcessCommand(line, "/ping"):
-			getPingPongHandler(i.conn, line, websocket.PingMessage)()
-		case shouldProcessCommand(line, "/pong"):
-			getPingPongHandler(i.conn, line, websocket.PongMessage)()
-		case shouldProcessCommand(line, "/close"):
-			closeHandler(i.conn, line)
-		case shouldProcessCommand(line, "/bfile"):
-			sendBinaryFile(i.conn, line)
-		default:
-			ws.WriteToServer(i.conn, websocket.TextMessage, []byte(line))
+		cmd, err := i.render(cmd)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		if !i.runCommand(cmd) {
+			write(client, cmd)
+		}
+	}
+}
+
+// handle runs the slash command in line or sends line to the active connection as a text message.
+func (i *Interactive) handle(line string) {
+	line, err := i.render(line)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if i.runCommand(line) {
+		return
+	}
+
+	if client := i.current(); client != nil {
+		write(client, line)
+	}
+}
+
+// runCommand runs line if it is a slash command and reports whether it was one.
+// The protocol commands are processed without --slash.
+func (i *Interactive) runCommand(line string) bool {
+	for _, cmd := range i.protocolCommands() {
+		if isCommand(line, cmd.name) {
+			cmd.handler(strings.TrimSpace(line[len(cmd.name):]))
+			return true
+		}
+	}
+
+	for _, cmd := range i.builtinCommands() {
+		if cmd.handler != nil && shouldProcessCommand(line, cmd.name) {
+			cmd.handler(strings.TrimSpace(line[len(cmd.name):]))
+			return true
+		}
+	}
+
+	return false
+}
+
+func write(client *ws.Client, line string) {
+	mt, message := websocket.TextMessage, []byte(line)
+
+	if adapter, ok := protocol.Of(client); ok {
+		var err error
+		if mt, message, err = adapter.Outgoing(line); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+
+	if err := client.Write(mt, message); err != nil {
+		logger.Err(err).Msg("error while writing to server")
+	}
+}
+
+func (i *Interactive) setPrompt() {
+	if i.term == nil {
+		return
+	}
+
+	i.mux.RLock()
+	client, name, multi := i.clients[i.active], i.active, len(i.clients) > 1 || i.listen != ""
+	i.mux.RUnlock()
+
+	if client == nil {
+		if i.listen != "" {
+			i.term.AppendPrompt(fmt.Sprintf("(listening %s)»", i.listen))
 		}
-	})
+		return
+	}
+
+	if multi {
+		i.term.AppendPrompt(fmt.Sprintf("(%s %s)»", name, truncateString(client.URL(), 25)))
+		return
+	}
+
+	i.term.AppendPrompt(fmt.Sprintf("(%s)»", truncateString(client.URL(), 25)))
+}
+
+func (i *Interactive) connect(connectURL string) {
+	if connectURL == "" {
+		log.Println("url is empty")
+		return
+	}
+
+	if i.listen != "" {
+		log.Println("/connect is not available with --listen, use /open <name> <url>")
+		return
+	}
+
+	if err := i.client().Connect(connectURL); err != nil {
+		log.Printf("connect err : %s", err)
+		return
+	}
+
+	log.Println(ws.GreenColor("Connected to %s", connectURL))
+	i.setPrompt()
+}
+
+func (i *Interactive) help(string) {
+	for _, cmd := range i.commands() {
+		log.Printf("%-25s %s", cmd.usage, cmd.help)
+	}
+}
+
+func wait(args string) {
+	dur, err := time.ParseDuration(args)
+	if err != nil {
+		log.Printf("invalid duration : %s", err)
+		return
+	}
 
+	time.Sleep(dur)
 }
 
-func sendBinaryFile(conn *websocket.Conn, line string) {
-	filePath := strings.TrimSpace(line[6:])
+func setFilter(expr string) {
+	if err := ws.SetFilter(expr); err != nil {
+		log.Println(err)
+		return
+	}
+
+	if expr == "" {
+		log.Println("filter removed")
+		return
+	}
+
+	log.Printf("filter set to %s", expr)
+}
+
+func printText(args string) {
+	log.Println(args)
+}
+
+func (i *Interactive) sendBinaryFile(filePath string) {
 	if filePath == "" {
 		log.Println("filepath is empty")
 		return
@@ -107,17 +313,32 @@ func sendBinaryFile(conn *websocket.Conn, line string) {
 		return
 	}
 
-	ws.WriteToServer(conn, websocket.BinaryMessage, fileData)
+	client := i.current()
+	if client == nil {
+		return
+	}
+
+	if err := client.Write(websocket.BinaryMessage, fileData); err != nil {
+		log.Printf("file send err : %s", err)
+		return
+	}
+
 	log.Println("file sent successfully")
 
 }
 
 func shouldProcessCommand(line, prefix string) bool {
-	if config.Flags.IsSlash && strings.HasPrefix(line, prefix) {
-		return true
+	return config.Flags.IsSlash && isCommand(line, prefix)
+}
+
+// isCommand reports whether line is the command prefix, the prefix must be the whole
+// command word, /closeall is not /close.
+func isCommand(line, prefix string) bool {
+	if !strings.HasPrefix(line, prefix) {
+		return false
 	}
 
-	return false
+	return len(line) == len(prefix) || line[len(prefix)] == ' '
 }
 
 func truncateString(s string, n int) string {
@@ -128,8 +349,7 @@ func truncateString(s string, n int) string {
 	return s
 }
 
-func closeHandler(conn *websocket.Conn, line string) {
-	str := strings.TrimSpace(line[6:])
+func (i *Interactive) closeHandler(str string) {
 	if len(str) > 0 {
 		spl := strings.Split(str, " ")
 		if len(spl) < 2 {
@@ -145,32 +365,40 @@ func closeHandler(conn *websocket.Conn, line string) {
 
 		reason := strings.TrimSpace(strings.Join(spl[1:], " "))
 
-		if err := conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(closeCode, reason), time.Now().Add(3*time.Second)); err != nil {
+		client := i.current()
+		if client == nil {
+			return
+		}
+
+		if err := client.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(closeCode, reason)); err != nil {
 			logger.Err(err).Msg("write close error")
 		}
 	}
 }
 
-func getPingPongHandler(conn *websocket.Conn, line string, mt int) func() {
-	return func() {
-		str := strings.TrimSpace(line[5:])
-		if err := conn.WriteControl(mt, []byte(str), time.Now().Add(3*time.Second)); err != nil {
+func (i *Interactive) pingPongHandler(mt int) func(string) {
+	return func(str string) {
+		client := i.current()
+		if client == nil {
+			return
+		}
+
+		if err := client.WriteControl(mt, []byte(str)); err != nil {
 			log.Println(err)
 		}
 	}
 }
 
-func catchSignals(conn *websocket.Conn, term *terminal.Term) {
+// catchSignals calls closef, e.g. Interactive.Close, and exits on SIGINT, SIGTERM or SIGHUP.
+func catchSignals(closef func(), term *terminal.Term) {
 	sigs := make(chan os.Signal, 2)
 
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	logger.Debug().Msgf("received signal %s", <-sigs)
 
-	if conn != nil {
-		if err := conn.Close(); err != nil {
-			logger.Debug().Err(err).Msg("error while closing connection")
-		}
+	if closef != nil {
+		closef()
 	}
 
 	if term != nil {
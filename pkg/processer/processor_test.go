@@ -1,9 +1,13 @@
 package processer
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/protocol"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
 )
 
 func TestTruncateString(t *testing.T) {
@@ -39,6 +43,14 @@ func TestShouldProcessCommand(t *testing.T) {
 		t.Error("shouldProcessCommand() = true, want false when prefix doesn't match")
 	}
 
+	if !shouldProcessCommand("/ping hello", "/ping") {
+		t.Error("shouldProcessCommand() = false, want true when command has arguments")
+	}
+
+	if shouldProcessCommand("/pingx", "/ping") {
+		t.Error("shouldProcessCommand() = true, want false when prefix is only part of the command")
+	}
+
 	config.Flags = &config.Flag{IsSlash: false}
 	if shouldProcessCommand("/ping", "/ping") {
 		t.Error("shouldProcessCommand() = true, want false when slash disabled")
@@ -64,3 +76,60 @@ func TestShouldProcessAsCmd(t *testing.T) {
 		t.Error("ShouldProcessAsCmd() = true, want false when no conditions")
 	}
 }
+
+func TestCommandsUsage(t *testing.T) {
+	i := New(nil, nil)
+	seen := map[string]bool{}
+	for _, cmd := range i.commands() {
+		if !strings.HasPrefix(cmd.usage, cmd.name) {
+			t.Errorf("usage %q does not start with command name %q", cmd.usage, cmd.name)
+		}
+		if cmd.help == "" {
+			t.Errorf("command %q has no help text", cmd.name)
+		}
+		if seen[cmd.name] {
+			t.Errorf("command %q is defined twice", cmd.name)
+		}
+		seen[cmd.name] = true
+	}
+
+	for _, name := range []string{"/connect", "/wait", "/help", "/print"} {
+		if !seen[name] {
+			t.Errorf("command %q is not defined", name)
+		}
+	}
+}
+
+func TestWait(t *testing.T) {
+	start := time.Now()
+	wait("50ms")
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("wait() returned before the given duration")
+	}
+
+	start = time.Now()
+	wait("invalid")
+	if time.Since(start) > 10*time.Millisecond {
+		t.Error("wait() with invalid duration should return immediately")
+	}
+}
+
+func TestProtocolCommands(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{}
+
+	client := ws.NewClient()
+	if _, err := protocol.Attach(protocol.SocketIO, client); err != nil {
+		t.Fatalf("Attach() error: %v", err)
+	}
+
+	i := New(client, nil)
+	if !i.runCommand("/namespace") {
+		t.Error("runCommand() did not run the protocol command without --slash")
+	}
+
+	if i.runCommand("/help") {
+		t.Error("runCommand() ran a builtin command without --slash")
+	}
+}
@@ -0,0 +1,88 @@
+package processer
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/akshaykhairmode/wscli/pkg/record"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+	"github.com/gorilla/websocket"
+)
+
+// replayWait is how long the replies are awaited after the last frame when --wait is not set.
+const replayWait = 2 * time.Second
+
+// Replay re-sends the outbound frames of a recorded session, keeping the original
+// gaps between them divided by the replay speed. The frames recorded as auto, e.g. the
+// keepalive pings, are skipped since the connection sends them by itself.
+func Replay(client *ws.Client) error {
+
+	frames, err := record.Read(config.Flags.Replay.File)
+	if err != nil {
+		return err
+	}
+
+	go catchSignals(client.Close, nil)
+
+	sent := 0
+	var prev time.Time
+
+	for _, fr := range frames {
+		if fr.Direction != record.Out || fr.Auto {
+			continue
+		}
+
+		if !prev.IsZero() {
+			time.Sleep(replayDelay(fr.Time.Sub(prev), config.Flags.Replay.Speed))
+		}
+		prev = fr.Time
+
+		if err := replayFrame(client, fr); err != nil {
+			return fmt.Errorf("error while replaying frame %d : %w", sent+1, err)
+		}
+
+		sent++
+	}
+
+	log.Printf("replayed %d frames", sent)
+
+	<-time.After(cmp.Or(config.Flags.Wait, replayWait))
+
+	return nil
+}
+
+// replayFrame sends the frame through the client, so that it does not write concurrently with
+// the protocol heartbeats and is printed like the typed messages.
+func replayFrame(client *ws.Client, fr record.Frame) error {
+
+	data, err := fr.Data()
+	if err != nil {
+		return fmt.Errorf("invalid payload : %w", err)
+	}
+
+	logger.Debug().Msgf("replaying %s frame of %d bytes", fr.Opcode, len(data))
+
+	switch mt := fr.MessageType(); mt {
+	case websocket.TextMessage, websocket.BinaryMessage:
+		return client.WriteRaw(mt, data)
+	case websocket.PingMessage, websocket.PongMessage:
+		return client.WriteControl(mt, data)
+	case websocket.CloseMessage:
+		return client.WriteControl(mt, websocket.FormatCloseMessage(fr.CloseCode, string(data)))
+	default:
+		return fmt.Errorf("unknown opcode %s", fr.Opcode)
+	}
+}
+
+// replayDelay scales a recorded gap by speed, a speed of 0 or less sends without delay.
+func replayDelay(gap time.Duration, speed float64) time.Duration {
+	if speed <= 0 || gap <= 0 {
+		return 0
+	}
+
+	return time.Duration(float64(gap) / speed)
+}
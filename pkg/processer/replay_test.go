@@ -0,0 +1,45 @@
+package processer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/record"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+	"github.com/gorilla/websocket"
+)
+
+func TestReplayDelay(t *testing.T) {
+	cases := []struct {
+		gap   time.Duration
+		speed float64
+		want  time.Duration
+	}{
+		{time.Second, 1, time.Second},
+		{time.Second, 2, 500 * time.Millisecond},
+		{time.Second, 0.5, 2 * time.Second},
+		{time.Second, 0, 0},
+		{-time.Second, 1, 0},
+	}
+
+	for _, c := range cases {
+		if got := replayDelay(c.gap, c.speed); got != c.want {
+			t.Errorf("replayDelay(%s, %v) = %s, want %s", c.gap, c.speed, got, c.want)
+		}
+	}
+}
+
+func TestReplayFrameClosed(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true}
+
+	//e.g. the client was closed by a signal while replaying.
+	client := ws.NewClient()
+	for _, mt := range []int{websocket.TextMessage, websocket.PingMessage, websocket.CloseMessage} {
+		if err := replayFrame(client, record.NewFrame(record.Out, mt, []byte("data"))); err == nil {
+			t.Errorf("replayFrame(%s) without connection should return error", record.OpcodeName(mt))
+		}
+	}
+}
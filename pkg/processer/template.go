@@ -0,0 +1,67 @@
+package processer
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/tmplfunc"
+)
+
+// literalDelim is how a literal {{ is written in a line, it is replaced by a template action printing {{.
+const literalDelim = `\{{`
+
+// render executes line as a template with the template functions and the /set variables.
+// The line is returned as is if --template is not set.
+func (i *Interactive) render(line string) (string, error) {
+	if !config.Flags.Template || !strings.Contains(line, "{{") {
+		return line, nil
+	}
+
+	tmpl, err := template.New("line").
+		Funcs(tmplfunc.FuncMap).
+		Option("missingkey=error").
+		Parse(strings.ReplaceAll(line, literalDelim, `{{"{{"}}`))
+	if err != nil {
+		return "", fmt.Errorf("error while parsing the template : %w", err)
+	}
+
+	i.mux.RLock()
+	vars := maps.Clone(i.vars)
+	i.mux.RUnlock()
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("error while executing the template : %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// set stores a variable used as {{.name}} in templates, without value the variable is removed
+// and without arguments the variables are listed.
+func (i *Interactive) set(args string) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+
+	if args == "" {
+		for _, name := range slices.Sorted(maps.Keys(i.vars)) {
+			log.Printf("%s = %s", name, i.vars[name])
+		}
+		return
+	}
+
+	name, value, _ := strings.Cut(args, " ")
+	value = strings.TrimSpace(value)
+	if value == "" {
+		delete(i.vars, name)
+		return
+	}
+
+	i.vars[name] = value
+}
@@ -0,0 +1,47 @@
+package processer
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+)
+
+func TestRender(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+
+	i := New(ws.NewClient(), nil)
+
+	config.Flags = &config.Flag{}
+	if got, err := i.render("{{.id}}"); err != nil || got != "{{.id}}" {
+		t.Errorf("render() without --template = %q, %v, want the line as is", got, err)
+	}
+
+	config.Flags = &config.Flag{Template: true}
+
+	i.set("id 42")
+	i.set("user  bob")
+	got, err := i.render(`{"id":{{.id}},"user":"{{.user}}","lit":"\{{.id}}"}`)
+	if err != nil {
+		t.Fatalf("render() error: %v", err)
+	}
+	if want := `{"id":42,"user":"bob","lit":"{{.id}}"}`; got != want {
+		t.Errorf("render() = %q, want %q", got, want)
+	}
+
+	got, err = i.render("{{RandomUUID}}")
+	if err != nil || !regexp.MustCompile(`^[0-9a-f-]{36}$`).MatchString(got) {
+		t.Errorf("render() with RandomUUID = %q, %v", got, err)
+	}
+
+	i.set("id")
+	if _, err := i.render("{{.id}}"); err == nil {
+		t.Error("render() with removed variable should return error")
+	}
+
+	if _, err := i.render("{{.id"); err == nil {
+		t.Error("render() with invalid template should return error")
+	}
+}
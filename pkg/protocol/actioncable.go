@@ -0,0 +1,263 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+	"github.com/gorilla/websocket"
+)
+
+var actionCableSubprotocols = []string{"actioncable-v1-json", "actioncable-unsupported"}
+
+// actionCable speaks the Rails Action Cable protocol. Channels are subscribed with /subscribe and
+// actions are performed with /perform, typed lines are sent as raw commands. Welcome and ping
+// messages are not printed.
+type actionCable struct {
+	client *ws.Client
+
+	mux     sync.Mutex
+	id      int
+	subs    map[string]string //identifiers of the subscriptions by id, subscribed again after a reconnect.
+	welcome chan struct{}     //closed when the welcome message is received.
+}
+
+type cableCommand struct {
+	Command    string `json:"command"`
+	Identifier string `json:"identifier"`
+	Data       string `json:"data,omitempty"`
+}
+
+func newActionCable(client *ws.Client) *actionCable {
+	return &actionCable{client: client, subs: map[string]string{}}
+}
+
+func (a *actionCable) Prepare(connectURL string) (string, []string, error) {
+	a.mux.Lock()
+	a.welcome = make(chan struct{})
+	a.mux.Unlock()
+
+	return connectURL, actionCableSubprotocols, nil
+}
+
+// Connected waits for the welcome message and subscribes the subscriptions again.
+func (a *actionCable) Connected() error {
+	a.mux.Lock()
+	welcome := a.welcome
+	a.mux.Unlock()
+
+	select {
+	case <-welcome:
+	case <-time.After(timeout()):
+		return fmt.Errorf("action cable welcome not received within %s", timeout())
+	}
+
+	a.mux.Lock()
+	resubscribe := maps.Clone(a.subs)
+	a.mux.Unlock()
+
+	for _, id := range slices.Sorted(maps.Keys(resubscribe)) {
+		if err := a.send(cableCommand{Command: "subscribe", Identifier: resubscribe[id]}); err != nil {
+			return err
+		}
+		log.Printf("subscription %s subscribed again", id)
+	}
+
+	return nil
+}
+
+func (a *actionCable) Incoming(mt int, message []byte) ([][]byte, bool) {
+	if mt != websocket.TextMessage {
+		return nil, false
+	}
+
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return nil, false
+	}
+
+	//messages of a malformed type or identifier are printed as received.
+	var typ string
+	if raw, ok := msg["type"]; ok {
+		if err := json.Unmarshal(raw, &typ); err != nil {
+			return [][]byte{message}, true
+		}
+	}
+
+	switch typ {
+	case "welcome":
+		a.markWelcome()
+		return nil, true
+	case "ping":
+		if !config.Flags.ShowPingPong {
+			return nil, true
+		}
+	case "reject_subscription":
+		var identifier string
+		if err := json.Unmarshal(msg["identifier"], &identifier); err != nil {
+			return [][]byte{message}, true
+		}
+		a.forget(identifier)
+	}
+
+	//the identifier is a JSON encoded string, it is printed as an object.
+	var identifier string
+	if err := json.Unmarshal(msg["identifier"], &identifier); err == nil && json.Valid([]byte(identifier)) {
+		msg["identifier"] = json.RawMessage(identifier)
+	}
+
+	return [][]byte{marshal(msg)}, true
+}
+
+func (a *actionCable) Outgoing(line string) (int, []byte, error) {
+	return websocket.TextMessage, []byte(line), nil
+}
+
+func (a *actionCable) Commands() []Command {
+	return []Command{
+		{"/subscribe", "/subscribe <channel> [params]", "Subscribe to a channel, params is a JSON object added to the identifier. The subscription id is printed.", a.subscribe},
+		{"/unsubscribe", "/unsubscribe <id>", "Remove a subscription.", a.unsubscribe},
+		{"/perform", "/perform <id> <action> [data]", "Perform an action of a subscription, data is a JSON object.", a.perform},
+		{"/subscriptions", "/subscriptions", "List the subscriptions.", a.subscriptions},
+	}
+}
+
+func (a *actionCable) subscribe(args string) error {
+	channel, params := splitWord(args)
+	if channel == "" {
+		return fmt.Errorf("usage: /subscribe <channel> [params]")
+	}
+
+	identifier, err := cableIdentifier(channel, params)
+	if err != nil {
+		return err
+	}
+
+	a.mux.Lock()
+	a.id++
+	id := strconv.Itoa(a.id)
+	a.subs[id] = identifier
+	a.mux.Unlock()
+
+	if err := a.send(cableCommand{Command: "subscribe", Identifier: identifier}); err != nil {
+		a.mux.Lock()
+		delete(a.subs, id)
+		a.mux.Unlock()
+		return err
+	}
+
+	log.Printf("subscribed to %s with id %s", channel, id)
+	return nil
+}
+
+func (a *actionCable) unsubscribe(id string) error {
+	if id == "" {
+		return fmt.Errorf("usage: /unsubscribe <id>")
+	}
+
+	a.mux.Lock()
+	identifier, ok := a.subs[id]
+	delete(a.subs, id)
+	a.mux.Unlock()
+
+	if !ok {
+		return fmt.Errorf("subscription %s does not exist", id)
+	}
+
+	return a.send(cableCommand{Command: "unsubscribe", Identifier: identifier})
+}
+
+func (a *actionCable) perform(args string) error {
+	id, rest := splitWord(args)
+	action, data := splitWord(rest)
+	if id == "" || action == "" {
+		return fmt.Errorf("usage: /perform <id> <action> [data]")
+	}
+
+	a.mux.Lock()
+	identifier, ok := a.subs[id]
+	a.mux.Unlock()
+
+	if !ok {
+		return fmt.Errorf("subscription %s does not exist", id)
+	}
+
+	fields := map[string]any{}
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &fields); err != nil {
+			return fmt.Errorf("data must be a JSON object : %w", err)
+		}
+	}
+	fields["action"] = action
+
+	return a.send(cableCommand{Command: "message", Identifier: identifier, Data: string(marshal(fields))})
+}
+
+func (a *actionCable) subscriptions(string) error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	if len(a.subs) == 0 {
+		log.Println("no subscriptions")
+		return nil
+	}
+
+	ids := slices.SortedFunc(maps.Keys(a.subs), func(x, y string) int {
+		i, _ := strconv.Atoi(x)
+		j, _ := strconv.Atoi(y)
+		return i - j
+	})
+
+	for _, id := range ids {
+		log.Printf("%-4s %s", id, a.subs[id])
+	}
+
+	return nil
+}
+
+// forget removes the subscriptions with identifier, e.g. when the server rejected it.
+func (a *actionCable) forget(identifier string) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	maps.DeleteFunc(a.subs, func(_, v string) bool { return v == identifier })
+}
+
+func (a *actionCable) send(cmd cableCommand) error {
+	return a.client.Send(websocket.TextMessage, marshal(cmd))
+}
+
+func (a *actionCable) markWelcome() {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	if a.welcome == nil {
+		return
+	}
+
+	select {
+	case <-a.welcome:
+	default:
+		close(a.welcome)
+	}
+}
+
+// cableIdentifier returns the identifier of a subscription: params with the channel added, JSON encoded.
+func cableIdentifier(channel, params string) (string, error) {
+	fields := map[string]any{}
+	if params != "" {
+		if err := json.Unmarshal([]byte(params), &fields); err != nil {
+			return "", fmt.Errorf("params must be a JSON object : %w", err)
+		}
+	}
+	fields["channel"] = channel
+
+	return string(marshal(fields)), nil
+}
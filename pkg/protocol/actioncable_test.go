@@ -0,0 +1,99 @@
+package protocol
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/gorilla/websocket"
+)
+
+func TestCableIdentifier(t *testing.T) {
+	identifier, err := cableIdentifier("ChatChannel", `{"room":"lobby"}`)
+	if err != nil || identifier != `{"channel":"ChatChannel","room":"lobby"}` {
+		t.Errorf("cableIdentifier() = %s, %v", identifier, err)
+	}
+
+	if identifier, _ := cableIdentifier("ChatChannel", ""); identifier != `{"channel":"ChatChannel"}` {
+		t.Errorf("cableIdentifier() without params = %s", identifier)
+	}
+
+	if _, err := cableIdentifier("ChatChannel", "[1]"); err == nil {
+		t.Error("cableIdentifier() with params which are not an object should return error")
+	}
+}
+
+func TestActionCableMalformed(t *testing.T) {
+	a := newActionCable(nil)
+	for _, message := range []string{`{"type":1}`, `{"type":"reject_subscription","identifier":{}}`} {
+		if msgs, ok := a.Incoming(websocket.TextMessage, []byte(message)); !ok || len(msgs) != 1 || string(msgs[0]) != message {
+			t.Errorf("Incoming(%s) = %q, %v, want the raw message", message, msgs, ok)
+		}
+	}
+}
+
+func TestActionCableSession(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true, PingInterval: time.Minute, Protocol: config.Protocol{Name: ActionCable}}
+
+	s := newSession(t, func(conn *websocket.Conn, message []byte) {
+		if message == nil {
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"welcome"}`))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"ping","message":1700000000}`))
+			return
+		}
+
+		var cmd cableCommand
+		if json.Unmarshal(message, &cmd) != nil {
+			return
+		}
+
+		identifier := string(marshal(cmd.Identifier))
+		switch cmd.Command {
+		case "subscribe":
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"identifier":`+identifier+`,"type":"confirm_subscription"}`))
+		case "message":
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"identifier":`+identifier+`,"message":`+cmd.Data+`}`))
+		}
+	})
+	defer s.close()
+	s.connect("")
+
+	if got := s.client.Conn().Subprotocol(); got != actionCableSubprotocols[0] {
+		t.Errorf("Subprotocol() = %q, want %s", got, actionCableSubprotocols[0])
+	}
+
+	expectSent := func(want cableCommand) {
+		t.Helper()
+		var got cableCommand
+		if err := json.Unmarshal([]byte(s.sent()), &got); err != nil || got != want {
+			t.Errorf("sent %+v, %v, want %+v", got, err, want)
+		}
+	}
+
+	c := s.adapter.(*actionCable)
+	if err := c.subscribe(`ChatChannel {"room":"lobby"}`); err != nil {
+		t.Fatalf("subscribe() error: %v", err)
+	}
+
+	identifier := `{"channel":"ChatChannel","room":"lobby"}`
+	expectSent(cableCommand{Command: "subscribe", Identifier: identifier})
+	s.expectReceived(`{"identifier":` + identifier + `,"type":"confirm_subscription"}`)
+
+	if err := c.perform(`1 speak {"message":"hi"}`); err != nil {
+		t.Fatalf("perform() error: %v", err)
+	}
+	expectSent(cableCommand{Command: "message", Identifier: identifier, Data: `{"action":"speak","message":"hi"}`})
+	s.expectReceived(`{"identifier":` + identifier + `,"message":{"action":"speak","message":"hi"}}`)
+
+	if err := c.perform("2 speak"); err == nil {
+		t.Error("perform() on an unknown subscription should return error")
+	}
+
+	if err := c.unsubscribe("1"); err != nil {
+		t.Fatalf("unsubscribe() error: %v", err)
+	}
+	expectSent(cableCommand{Command: "unsubscribe", Identifier: identifier})
+}
@@ -0,0 +1,302 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+	"github.com/gorilla/websocket"
+)
+
+const graphqlSubprotocol = "graphql-transport-ws"
+
+// graphql speaks the graphql-transport-ws protocol. Operations are started with /subscribe,
+// typed lines are sent as raw protocol messages.
+type graphql struct {
+	client *ws.Client
+
+	mux   sync.Mutex
+	id    int
+	ops   map[string]*graphqlOperation //active operations by id, subscribed again after a reconnect.
+	acked chan struct{}                //closed when connection_ack is received.
+}
+
+type graphqlOperation struct {
+	name    string
+	payload json.RawMessage
+	started time.Time
+}
+
+type graphqlMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type graphqlRequest struct {
+	Query         string          `json:"query"`
+	Variables     json.RawMessage `json:"variables,omitempty"`
+	OperationName string          `json:"operationName,omitempty"`
+}
+
+func newGraphQL(client *ws.Client) *graphql {
+	return &graphql{client: client, ops: map[string]*graphqlOperation{}}
+}
+
+func (g *graphql) Prepare(connectURL string) (string, []string, error) {
+	g.mux.Lock()
+	g.acked = make(chan struct{})
+	g.mux.Unlock()
+
+	return connectURL, []string{graphqlSubprotocol}, nil
+}
+
+// Connected sends connection_init, waits for connection_ack and subscribes the active operations again.
+func (g *graphql) Connected() error {
+	init := graphqlMessage{Type: "connection_init"}
+	if payload := config.Flags.Protocol.Payload; payload != "" {
+		init.Payload = json.RawMessage(payload)
+	}
+
+	if err := g.send(init); err != nil {
+		return err
+	}
+
+	g.mux.Lock()
+	acked := g.acked
+	g.mux.Unlock()
+
+	select {
+	case <-acked:
+	case <-time.After(timeout()):
+		return fmt.Errorf("graphql connection_ack not received within %s", timeout())
+	}
+
+	g.mux.Lock()
+	resubscribe := map[string]json.RawMessage{}
+	for id, op := range g.ops {
+		resubscribe[id] = op.payload
+	}
+	g.mux.Unlock()
+
+	for _, id := range slices.Sorted(maps.Keys(resubscribe)) {
+		if err := g.send(graphqlMessage{ID: id, Type: "subscribe", Payload: resubscribe[id]}); err != nil {
+			return err
+		}
+		log.Printf("operation %s subscribed again", id)
+	}
+
+	return nil
+}
+
+func (g *graphql) Incoming(mt int, message []byte) ([][]byte, bool) {
+	if mt != websocket.TextMessage {
+		return nil, false
+	}
+
+	var msg graphqlMessage
+	if err := json.Unmarshal(message, &msg); err != nil || msg.Type == "" {
+		return nil, false
+	}
+
+	switch msg.Type {
+	case "connection_ack":
+		g.markAcked()
+	case "ping":
+		if err := g.send(graphqlMessage{Type: "pong"}); err != nil {
+			log.Println(err)
+		}
+		if !config.Flags.ShowPingPong {
+			return nil, true
+		}
+	case "pong":
+		if !config.Flags.ShowPingPong {
+			return nil, true
+		}
+	case "next", "error", "complete":
+		g.mux.Lock()
+		var name string
+		if op, ok := g.ops[msg.ID]; ok {
+			name = op.name
+		}
+		if msg.Type != "next" {
+			delete(g.ops, msg.ID)
+		}
+		g.mux.Unlock()
+
+		return [][]byte{prettyGraphQL(msg, name, message)}, true
+	}
+
+	return [][]byte{message}, true
+}
+
+// prettyGraphQL renders a result of an operation indented, tagged with the operation id, type and
+// name. It stays JSON so that --filter and the scripts can use it, message is returned if it fails.
+func prettyGraphQL(msg graphqlMessage, name string, message []byte) []byte {
+	pretty, err := json.MarshalIndent(struct {
+		ID        string          `json:"id"`
+		Type      string          `json:"type"`
+		Operation string          `json:"operation,omitempty"`
+		Payload   json.RawMessage `json:"payload,omitempty"`
+	}{msg.ID, msg.Type, name, msg.Payload}, "", "  ")
+	if err != nil {
+		return message
+	}
+
+	return pretty
+}
+
+func (g *graphql) Outgoing(line string) (int, []byte, error) {
+	return websocket.TextMessage, []byte(line), nil
+}
+
+func (g *graphql) Commands() []Command {
+	return []Command{
+		{"/subscribe", "/subscribe <file.graphql|query> [variables]", "Start an operation from a .graphql file or an inline query, variables are JSON or @file.json.", g.subscribe},
+		{"/operations", "/operations", "List the active operations.", g.operations},
+		{"/complete", "/complete <id>", "Complete an active operation.", g.complete},
+	}
+}
+
+func (g *graphql) subscribe(args string) error {
+	if args == "" {
+		return fmt.Errorf("usage: /subscribe <file.graphql|query> [variables]")
+	}
+
+	req, name, err := parseGraphQLArgs(args)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	g.mux.Lock()
+	g.id++
+	id := strconv.Itoa(g.id)
+	g.ops[id] = &graphqlOperation{name: name, payload: payload, started: time.Now()}
+	g.mux.Unlock()
+
+	if err := g.send(graphqlMessage{ID: id, Type: "subscribe", Payload: payload}); err != nil {
+		g.mux.Lock()
+		delete(g.ops, id)
+		g.mux.Unlock()
+		return err
+	}
+
+	log.Printf("started operation %s (%s)", id, name)
+	return nil
+}
+
+func (g *graphql) operations(string) error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	if len(g.ops) == 0 {
+		log.Println("no active operations")
+		return nil
+	}
+
+	ids := slices.SortedFunc(maps.Keys(g.ops), func(a, b string) int {
+		x, _ := strconv.Atoi(a)
+		y, _ := strconv.Atoi(b)
+		return x - y
+	})
+
+	for _, id := range ids {
+		op := g.ops[id]
+		log.Printf("%-4s %-30s running for %s", id, op.name, time.Since(op.started).Round(time.Second))
+	}
+
+	return nil
+}
+
+func (g *graphql) complete(id string) error {
+	g.mux.Lock()
+	_, ok := g.ops[id]
+	delete(g.ops, id)
+	g.mux.Unlock()
+
+	if !ok {
+		return fmt.Errorf("operation %s is not active", id)
+	}
+
+	return g.send(graphqlMessage{ID: id, Type: "complete"})
+}
+
+func (g *graphql) send(msg graphqlMessage) error {
+	return g.client.Send(websocket.TextMessage, marshal(msg))
+}
+
+func (g *graphql) markAcked() {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	if g.acked == nil {
+		return
+	}
+
+	select {
+	case <-g.acked:
+	default:
+		close(g.acked)
+	}
+}
+
+// parseGraphQLArgs returns the request for `<file.graphql> [variables]` or an inline query,
+// and the name shown in /operations. Variables are JSON or @ followed by a JSON file path.
+func parseGraphQLArgs(args string) (graphqlRequest, string, error) {
+	path, vars := splitWord(args)
+
+	if _, err := os.Stat(path); err != nil {
+		//not a file, the arguments are an inline query.
+		return graphqlRequest{Query: args}, operationName(args, "inline"), nil
+	}
+
+	query, err := os.ReadFile(path)
+	if err != nil {
+		return graphqlRequest{}, "", fmt.Errorf("error while reading the query : %w", err)
+	}
+
+	req := graphqlRequest{Query: string(query)}
+
+	if strings.HasPrefix(vars, "@") {
+		data, err := os.ReadFile(vars[1:])
+		if err != nil {
+			return graphqlRequest{}, "", fmt.Errorf("error while reading the variables : %w", err)
+		}
+		vars = string(data)
+	}
+
+	if vars != "" {
+		if !json.Valid([]byte(vars)) {
+			return graphqlRequest{}, "", fmt.Errorf("variables are not valid JSON")
+		}
+		req.Variables = json.RawMessage(vars)
+	}
+
+	return req, operationName(req.Query, filepath.Base(path)), nil
+}
+
+var operationNameRe = regexp.MustCompile(`\b(?:query|mutation|subscription)\s+([_A-Za-z][_0-9A-Za-z]*)`)
+
+// operationName returns the name of the first named operation in query, fallback otherwise.
+func operationName(query, fallback string) string {
+	if m := operationNameRe.FindStringSubmatch(query); m != nil {
+		return m[1]
+	}
+	return fallback
+}
@@ -0,0 +1,142 @@
+package protocol
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/gorilla/websocket"
+)
+
+func TestOperationName(t *testing.T) {
+	cases := map[string]string{
+		"subscription OnMessage($room: ID!) { message(room: $room) { id } }": "OnMessage",
+		"query Me{ me { id } }": "Me",
+		"{ me { id } }":         "inline",
+		"subscription { a }":    "inline",
+	}
+
+	for query, want := range cases {
+		if got := operationName(query, "inline"); got != want {
+			t.Errorf("operationName(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestParseGraphQLArgs(t *testing.T) {
+	dir := t.TempDir()
+	query := filepath.Join(dir, "messages.graphql")
+	vars := filepath.Join(dir, "vars.json")
+	os.WriteFile(query, []byte("subscription Messages($room: ID!) { messages(room: $room) { text } }"), 0o644)
+	os.WriteFile(vars, []byte(`{"room":"b"}`), 0o644)
+
+	req, name, err := parseGraphQLArgs(query + ` {"room":"a"}`)
+	if err != nil || name != "Messages" || string(req.Variables) != `{"room":"a"}` {
+		t.Errorf("parseGraphQLArgs() = %+v, %q, %v", req, name, err)
+	}
+
+	req, _, err = parseGraphQLArgs(query + " @" + vars)
+	if err != nil || string(req.Variables) != `{"room":"b"}` {
+		t.Errorf("parseGraphQLArgs() with variables file = %+v, %v", req, err)
+	}
+
+	if _, _, err := parseGraphQLArgs(query + " {invalid"); err == nil {
+		t.Error("parseGraphQLArgs() with invalid variables should return error")
+	}
+
+	req, name, err = parseGraphQLArgs("{ me { id } }")
+	if err != nil || req.Query != "{ me { id } }" || name != "inline" {
+		t.Errorf("parseGraphQLArgs() inline = %+v, %q, %v", req, name, err)
+	}
+}
+
+func TestGraphQLSession(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true, PingInterval: time.Minute, Protocol: config.Protocol{Name: GraphQL, Payload: `{"token":"t"}`}}
+
+	s := newSession(t, func(conn *websocket.Conn, message []byte) {
+		var msg graphqlMessage
+		if json.Unmarshal(message, &msg) != nil {
+			return
+		}
+
+		switch msg.Type {
+		case "connection_init":
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"connection_ack"}`))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"ping"}`))
+		case "subscribe":
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"id":"`+msg.ID+`","type":"next","payload":{"data":{"n":1}}}`))
+		}
+	})
+	defer s.close()
+	s.connect("")
+
+	if got := s.client.Conn().Subprotocol(); got != graphqlSubprotocol {
+		t.Errorf("Subprotocol() = %q, want %s", got, graphqlSubprotocol)
+	}
+
+	expectSent := func(want string) graphqlMessage {
+		t.Helper()
+		var got graphqlMessage
+		if err := json.Unmarshal([]byte(s.sent()), &got); err != nil || got.Type != want {
+			t.Errorf("sent %+v, %v, want %s", got, err, want)
+		}
+		return got
+	}
+
+	if init := expectSent("connection_init"); string(init.Payload) != `{"token":"t"}` {
+		t.Errorf("connection_init payload = %s", init.Payload)
+	}
+	expectSent("pong")
+
+	g := s.adapter.(*graphql)
+	if err := g.subscribe("subscription OnTick { tick }"); err != nil {
+		t.Fatalf("subscribe() error: %v", err)
+	}
+
+	sub := expectSent("subscribe")
+	var req graphqlRequest
+	if err := json.Unmarshal(sub.Payload, &req); err != nil || sub.ID != "1" || req.Query != "subscription OnTick { tick }" {
+		t.Errorf("subscribe message = %+v, %v", sub, err)
+	}
+
+	s.expectReceived(`{"type":"connection_ack"}`)
+	s.expectReceived(`{
+  "id": "1",
+  "type": "next",
+  "operation": "OnTick",
+  "payload": {
+    "data": {
+      "n": 1
+    }
+  }
+}`)
+
+	if err := g.complete("1"); err != nil {
+		t.Fatalf("complete() error: %v", err)
+	}
+	if done := expectSent("complete"); done.ID != "1" {
+		t.Errorf("complete id = %s, want 1", done.ID)
+	}
+
+	if err := g.complete("1"); err == nil {
+		t.Error("complete() of a completed operation should return error")
+	}
+}
+
+func TestPrettyGraphQL(t *testing.T) {
+	msg := graphqlMessage{ID: "2", Type: "complete"}
+	if got := string(prettyGraphQL(msg, "", nil)); got != "{\n  \"id\": \"2\",\n  \"type\": \"complete\"\n}" {
+		t.Errorf("prettyGraphQL() of complete = %q", got)
+	}
+
+	msg = graphqlMessage{ID: "3", Type: "error", Payload: json.RawMessage(`[{"message":"boom"}]`)}
+	want := "{\n  \"id\": \"3\",\n  \"type\": \"error\",\n  \"operation\": \"OnTick\",\n  \"payload\": [\n    {\n      \"message\": \"boom\"\n    }\n  ]\n}"
+	if got := string(prettyGraphQL(msg, "OnTick", nil)); got != want {
+		t.Errorf("prettyGraphQL() of error = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,270 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+	"github.com/gorilla/websocket"
+)
+
+// jsonRPC speaks JSON-RPC 2.0. /call waits for the response of the request, responses are
+// printed with the method of the request and notifications are printed as notifications.
+// Typed lines are sent as raw messages.
+type jsonRPC struct {
+	client *ws.Client
+
+	mux     sync.Mutex
+	id      int
+	pending map[string]*rpcCall //calls waiting for a response, by JSON encoded id.
+}
+
+type rpcCall struct {
+	method string
+	sent   time.Time
+	done   chan struct{}
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int            `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcMessage is a received response, notification or request from the server.
+type rpcMessage struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+// rpcDisplay is how a received message is printed.
+type rpcDisplay struct {
+	Type   string          `json:"type"`
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+	RTT    string          `json:"rtt,omitempty"`
+}
+
+type rpcBatchCall struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Notify bool            `json:"notify,omitempty"`
+}
+
+func newJSONRPC(client *ws.Client) *jsonRPC {
+	return &jsonRPC{client: client, pending: map[string]*rpcCall{}}
+}
+
+func (j *jsonRPC) Prepare(connectURL string) (string, []string, error) {
+	return connectURL, nil, nil
+}
+
+func (j *jsonRPC) Connected() error {
+	return nil
+}
+
+func (j *jsonRPC) Incoming(mt int, message []byte) ([][]byte, bool) {
+	if mt != websocket.TextMessage {
+		return nil, false
+	}
+
+	trimmed := bytes.TrimSpace(message)
+
+	var msgs []rpcMessage
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		if err := json.Unmarshal(trimmed, &msgs); err != nil {
+			return nil, false
+		}
+	} else {
+		var msg rpcMessage
+		if err := json.Unmarshal(trimmed, &msg); err != nil {
+			return nil, false
+		}
+		msgs = []rpcMessage{msg}
+	}
+
+	var out [][]byte
+	for _, msg := range msgs {
+		out = append(out, marshal(j.display(msg)))
+	}
+
+	return out, true
+}
+
+func (j *jsonRPC) display(msg rpcMessage) rpcDisplay {
+	d := rpcDisplay{ID: msg.ID, Method: msg.Method, Params: msg.Params, Result: msg.Result, Error: msg.Error}
+
+	switch {
+	case msg.Method != "" && len(msg.ID) == 0:
+		d.Type = "notification"
+	case msg.Method != "":
+		d.Type = "request"
+	case len(msg.Error) > 0:
+		d.Type = "error"
+	default:
+		d.Type = "result"
+	}
+
+	if d.Type != "result" && d.Type != "error" {
+		return d
+	}
+
+	j.mux.Lock()
+	call, ok := j.pending[string(msg.ID)]
+	delete(j.pending, string(msg.ID))
+	j.mux.Unlock()
+
+	if ok {
+		d.Method = call.method
+		d.RTT = time.Since(call.sent).Round(time.Microsecond).String()
+		close(call.done)
+	}
+
+	return d
+}
+
+func (j *jsonRPC) Outgoing(line string) (int, []byte, error) {
+	return websocket.TextMessage, []byte(line), nil
+}
+
+func (j *jsonRPC) Commands() []Command {
+	return []Command{
+		{"/call", "/call <method> [params]", "Call a method and wait for the response, params are a JSON array or object.", j.call},
+		{"/notify", "/notify <method> [params]", "Send a notification, no response is expected.", j.notify},
+		{"/batch", `/batch [{"method":"a","params":[1]},{"method":"b","notify":true}]`, "Send a batch of calls and notifications and wait for the responses.", j.batch},
+	}
+}
+
+func (j *jsonRPC) call(args string) error {
+	method, params, err := parseRPCArgs(args)
+	if err != nil {
+		return err
+	}
+
+	req, call := j.request(method, params)
+	if err := j.client.Send(websocket.TextMessage, marshal(req)); err != nil {
+		j.forget(*req.ID)
+		return err
+	}
+
+	return j.wait([]*rpcCall{call}, []int{*req.ID})
+}
+
+func (j *jsonRPC) notify(args string) error {
+	method, params, err := parseRPCArgs(args)
+	if err != nil {
+		return err
+	}
+
+	return j.client.Send(websocket.TextMessage, marshal(rpcRequest{JSONRPC: "2.0", Method: method, Params: params}))
+}
+
+func (j *jsonRPC) batch(args string) error {
+	var calls []rpcBatchCall
+	if err := json.Unmarshal([]byte(args), &calls); err != nil || len(calls) == 0 {
+		return fmt.Errorf("usage: /batch [{\"method\":\"a\",\"params\":[1]},{\"method\":\"b\",\"notify\":true}]")
+	}
+
+	var (
+		reqs    []rpcRequest
+		pending []*rpcCall
+		ids     []int
+	)
+
+	for _, c := range calls {
+		if c.Method == "" {
+			return fmt.Errorf("every call of the batch needs a method")
+		}
+
+		if c.Notify {
+			reqs = append(reqs, rpcRequest{JSONRPC: "2.0", Method: c.Method, Params: c.Params})
+			continue
+		}
+
+		req, call := j.request(c.Method, c.Params)
+		reqs, pending, ids = append(reqs, req), append(pending, call), append(ids, *req.ID)
+	}
+
+	if err := j.client.Send(websocket.TextMessage, marshal(reqs)); err != nil {
+		for _, id := range ids {
+			j.forget(id)
+		}
+		return err
+	}
+
+	return j.wait(pending, ids)
+}
+
+// request creates a request with the next id and registers it as pending.
+func (j *jsonRPC) request(method string, params json.RawMessage) (rpcRequest, *rpcCall) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	j.id++
+	id := j.id
+
+	call := &rpcCall{method: method, sent: time.Now(), done: make(chan struct{})}
+	j.pending[strconv.Itoa(id)] = call
+
+	return rpcRequest{JSONRPC: "2.0", ID: &id, Method: method, Params: params}, call
+}
+
+// wait blocks until all calls got a response or the protocol timeout passed.
+func (j *jsonRPC) wait(calls []*rpcCall, ids []int) error {
+	deadline := time.After(timeout())
+
+	for i, call := range calls {
+		select {
+		case <-call.done:
+		case <-deadline:
+			for _, id := range ids[i:] {
+				j.forget(id)
+			}
+			return fmt.Errorf("no response for id %d (%s) within %s", ids[i], call.method, timeout())
+		}
+	}
+
+	return nil
+}
+
+func (j *jsonRPC) forget(id int) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	delete(j.pending, strconv.Itoa(id))
+}
+
+// parseRPCArgs parses `<method> [params]`, params must be a JSON array or object.
+func parseRPCArgs(args string) (string, json.RawMessage, error) {
+	method, params := splitWord(args)
+	if method == "" {
+		return "", nil, fmt.Errorf("method is empty")
+	}
+
+	if params == "" {
+		return method, nil, nil
+	}
+
+	var v any
+	if err := json.Unmarshal([]byte(params), &v); err != nil {
+		return "", nil, fmt.Errorf("params are not valid JSON : %w", err)
+	}
+
+	switch v.(type) {
+	case []any, map[string]any:
+	default:
+		return "", nil, fmt.Errorf("params must be a JSON array or object")
+	}
+
+	return method, json.RawMessage(params), nil
+}
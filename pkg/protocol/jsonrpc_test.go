@@ -0,0 +1,120 @@
+package protocol
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/gorilla/websocket"
+)
+
+func TestParseRPCArgs(t *testing.T) {
+	method, params, err := parseRPCArgs(`add [1,2]`)
+	if err != nil || method != "add" || string(params) != "[1,2]" {
+		t.Errorf("parseRPCArgs() = %q, %s, %v", method, params, err)
+	}
+
+	method, params, err = parseRPCArgs("ping")
+	if err != nil || method != "ping" || params != nil {
+		t.Errorf("parseRPCArgs() without params = %q, %s, %v", method, params, err)
+	}
+
+	for _, args := range []string{"", "add 1", "add {invalid"} {
+		if _, _, err := parseRPCArgs(args); err == nil {
+			t.Errorf("parseRPCArgs(%q) should return error", args)
+		}
+	}
+}
+
+func TestJSONRPCSession(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true, PingInterval: time.Minute, Protocol: config.Protocol{Name: JSONRPC, Timeout: 500 * time.Millisecond}}
+
+	reply := func(req rpcRequest) map[string]any {
+		if req.Method == "fail" {
+			return map[string]any{"jsonrpc": "2.0", "id": req.ID, "error": map[string]any{"code": -32601, "message": "Method not found"}}
+		}
+		return map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": req.Method}
+	}
+
+	s := newSession(t, func(conn *websocket.Conn, msg []byte) {
+		if msg == nil {
+			return
+		}
+
+		if strings.HasPrefix(string(msg), "[") {
+			var reqs []rpcRequest
+			json.Unmarshal(msg, &reqs)
+			var replies []map[string]any
+			for _, req := range reqs {
+				if req.ID != nil {
+					replies = append(replies, reply(req))
+				}
+			}
+			conn.WriteJSON(replies)
+			return
+		}
+
+		var req rpcRequest
+		json.Unmarshal(msg, &req)
+		if req.Method != "silent" && req.ID != nil {
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"tick","params":[1]}`))
+			conn.WriteJSON(reply(req))
+		}
+	})
+	defer s.close()
+	s.connect("")
+
+	expectReceived := func(typ, method string) rpcDisplay {
+		t.Helper()
+		var got rpcDisplay
+		if err := json.Unmarshal([]byte(s.message()), &got); err != nil || got.Type != typ || got.Method != method {
+			t.Errorf("received %+v, %v, want %s %s", got, err, typ, method)
+		}
+		return got
+	}
+
+	j := s.adapter.(*jsonRPC)
+	if err := j.call(`add [1,2]`); err != nil {
+		t.Fatalf("call() error: %v", err)
+	}
+	s.expectSent(`{"jsonrpc":"2.0","id":1,"method":"add","params":[1,2]}`)
+	expectReceived("notification", "tick")
+	if got := expectReceived("result", "add"); string(got.Result) != `"add"` || got.RTT == "" {
+		t.Errorf("result = %+v", got)
+	}
+
+	if err := j.call("fail"); err != nil {
+		t.Fatalf("call() error: %v", err)
+	}
+	s.expectSent(`{"jsonrpc":"2.0","id":2,"method":"fail"}`)
+	expectReceived("notification", "tick")
+	expectReceived("error", "fail")
+
+	if err := j.notify(`log {"level":"info"}`); err != nil {
+		t.Fatalf("notify() error: %v", err)
+	}
+	s.expectSent(`{"jsonrpc":"2.0","method":"log","params":{"level":"info"}}`)
+
+	if err := j.batch(`[{"method":"a","params":[1]},{"method":"b","notify":true},{"method":"c"}]`); err != nil {
+		t.Fatalf("batch() error: %v", err)
+	}
+	s.expectSent(`[{"jsonrpc":"2.0","id":3,"method":"a","params":[1]},{"jsonrpc":"2.0","method":"b"},{"jsonrpc":"2.0","id":4,"method":"c"}]`)
+	expectReceived("result", "a")
+	expectReceived("result", "c")
+
+	if err := j.call("silent"); err == nil {
+		t.Error("call() without response should return error")
+	}
+	s.expectSent(`{"jsonrpc":"2.0","id":5,"method":"silent"}`)
+
+	j.mux.Lock()
+	pending := len(j.pending)
+	j.mux.Unlock()
+	if pending != 0 {
+		t.Errorf("%d calls still pending after the timeout", pending)
+	}
+}
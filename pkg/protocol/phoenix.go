@@ -0,0 +1,339 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+	"github.com/gorilla/websocket"
+)
+
+const phoenixTopic = "phoenix"
+
+// phoenix speaks the Phoenix Channels V2 serializer. Topics are joined with /join, events are sent
+// with /push and typed lines are sent as raw messages. Heartbeats keep the socket alive.
+type phoenix struct {
+	client *ws.Client
+
+	mux      sync.Mutex
+	ref      int
+	joined   map[string]*phoenixJoin //joined topics, joined again after a reconnect.
+	stopBeat chan struct{}           //stops the heartbeat of the current connection.
+}
+
+type phoenixJoin struct {
+	ref     string //ref of the phx_join, sent as join_ref with every message of the topic.
+	payload json.RawMessage
+}
+
+// phoenixMessage is how a received message is printed.
+type phoenixMessage struct {
+	JoinRef *string         `json:"join_ref,omitempty"`
+	Ref     *string         `json:"ref,omitempty"`
+	Topic   string          `json:"topic"`
+	Event   string          `json:"event"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+func newPhoenix(client *ws.Client) *phoenix {
+	return &phoenix{client: client, joined: map[string]*phoenixJoin{}}
+}
+
+// Prepare adds the /websocket path suffix of the Phoenix transport and the serializer version.
+func (p *phoenix) Prepare(connectURL string) (string, []string, error) {
+	u, err := url.Parse(connectURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("error while parsing the url : %w", err)
+	}
+
+	if !strings.HasSuffix(u.Path, "/websocket") {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/websocket"
+	}
+
+	q := u.Query()
+	q.Set("vsn", "2.0.0")
+	u.RawQuery = q.Encode()
+
+	p.stopHeartbeat()
+
+	return u.String(), nil, nil
+}
+
+// Connected starts the heartbeat and joins the joined topics again.
+func (p *phoenix) Connected() error {
+	p.startHeartbeat()
+
+	p.mux.Lock()
+	rejoin := map[string]json.RawMessage{}
+	for topic, join := range p.joined {
+		rejoin[topic] = join.payload
+	}
+	p.mux.Unlock()
+
+	for _, topic := range slices.Sorted(maps.Keys(rejoin)) {
+		if err := p.join(topic, rejoin[topic]); err != nil {
+			return err
+		}
+		log.Printf("joining %s again", topic)
+	}
+
+	return nil
+}
+
+func (p *phoenix) Incoming(mt int, message []byte) ([][]byte, bool) {
+	if mt != websocket.TextMessage {
+		return nil, false
+	}
+
+	msg, err := decodePhoenix(message)
+	if err != nil {
+		return nil, false
+	}
+
+	if msg.Topic == phoenixTopic && msg.Event == "phx_reply" && !config.Flags.ShowPingPong {
+		return nil, true
+	}
+
+	switch msg.Event {
+	case "phx_reply":
+		status, err := replyStatus(msg.Payload)
+		if err != nil {
+			return [][]byte{message}, true
+		}
+		if msg.JoinRef != nil && msg.Ref != nil && *msg.JoinRef == *msg.Ref && status != "ok" {
+			p.forget(msg.Topic, *msg.JoinRef)
+		}
+	case "phx_close", "phx_error":
+		if msg.JoinRef != nil {
+			p.forget(msg.Topic, *msg.JoinRef)
+		}
+	}
+
+	return [][]byte{marshal(msg)}, true
+}
+
+func (p *phoenix) Outgoing(line string) (int, []byte, error) {
+	return websocket.TextMessage, []byte(line), nil
+}
+
+func (p *phoenix) Commands() []Command {
+	return []Command{
+		{"/join", "/join <topic> [payload]", "Join a topic, the payload is a JSON object.", p.joinCmd},
+		{"/leave", "/leave <topic>", "Leave a joined topic.", p.leave},
+		{"/push", "/push <topic> <event> [payload]", "Push an event to a topic, the payload is a JSON object.", p.push},
+		{"/topics", "/topics", "List the joined topics.", p.topics},
+	}
+}
+
+func (p *phoenix) joinCmd(args string) error {
+	topic, payload := splitWord(args)
+	if topic == "" {
+		return fmt.Errorf("usage: /join <topic> [payload]")
+	}
+
+	raw, err := phoenixPayload(payload)
+	if err != nil {
+		return err
+	}
+
+	return p.join(topic, raw)
+}
+
+func (p *phoenix) join(topic string, payload json.RawMessage) error {
+	p.mux.Lock()
+	ref := p.nextRef()
+	p.joined[topic] = &phoenixJoin{ref: ref, payload: payload}
+	p.mux.Unlock()
+
+	if err := p.send(&ref, &ref, topic, "phx_join", payload); err != nil {
+		p.forget(topic, ref)
+		return err
+	}
+
+	return nil
+}
+
+func (p *phoenix) leave(topic string) error {
+	if topic == "" {
+		return fmt.Errorf("usage: /leave <topic>")
+	}
+
+	p.mux.Lock()
+	join, ok := p.joined[topic]
+	delete(p.joined, topic)
+	ref := p.nextRef()
+	p.mux.Unlock()
+
+	if !ok {
+		return fmt.Errorf("topic %s is not joined", topic)
+	}
+
+	return p.send(&join.ref, &ref, topic, "phx_leave", json.RawMessage("{}"))
+}
+
+func (p *phoenix) push(args string) error {
+	topic, rest := splitWord(args)
+	event, payload := splitWord(rest)
+	if topic == "" || event == "" {
+		return fmt.Errorf("usage: /push <topic> <event> [payload]")
+	}
+
+	raw, err := phoenixPayload(payload)
+	if err != nil {
+		return err
+	}
+
+	p.mux.Lock()
+	var joinRef *string
+	if join, ok := p.joined[topic]; ok {
+		joinRef = &join.ref
+	}
+	ref := p.nextRef()
+	p.mux.Unlock()
+
+	return p.send(joinRef, &ref, topic, event, raw)
+}
+
+func (p *phoenix) topics(string) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if len(p.joined) == 0 {
+		log.Println("no joined topics")
+		return nil
+	}
+
+	for _, topic := range slices.Sorted(maps.Keys(p.joined)) {
+		log.Printf("%-30s join_ref %s", topic, p.joined[topic].ref)
+	}
+
+	return nil
+}
+
+// nextRef returns the next message ref, p.mux must be held.
+func (p *phoenix) nextRef() string {
+	p.ref++
+	return strconv.Itoa(p.ref)
+}
+
+// forget removes topic if it is still joined with ref, e.g. when the join was refused.
+func (p *phoenix) forget(topic, ref string) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if join, ok := p.joined[topic]; ok && join.ref == ref {
+		delete(p.joined, topic)
+	}
+}
+
+func (p *phoenix) send(joinRef, ref *string, topic, event string, payload json.RawMessage) error {
+	return p.client.Send(websocket.TextMessage, marshal([]any{joinRef, ref, topic, event, payload}))
+}
+
+// startHeartbeat sends a heartbeat to the phoenix topic every --heartbeat, the server closes
+// sockets without heartbeat after 60s.
+func (p *phoenix) startHeartbeat() {
+	p.stopHeartbeat()
+
+	interval := config.Flags.Protocol.Heartbeat
+	if interval <= 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	p.mux.Lock()
+	p.stopBeat = stop
+	p.mux.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				p.mux.Lock()
+				ref := p.nextRef()
+				p.mux.Unlock()
+
+				if err := p.send(nil, &ref, phoenixTopic, "heartbeat", json.RawMessage("{}")); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (p *phoenix) stopHeartbeat() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.stopBeat != nil {
+		close(p.stopBeat)
+		p.stopBeat = nil
+	}
+}
+
+// decodePhoenix decodes a `[join_ref, ref, topic, event, payload]` message.
+func decodePhoenix(message []byte) (phoenixMessage, error) {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(message, &fields); err != nil {
+		return phoenixMessage{}, err
+	}
+
+	if len(fields) != 5 {
+		return phoenixMessage{}, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+
+	var msg phoenixMessage
+	if err := json.Unmarshal(fields[0], &msg.JoinRef); err != nil {
+		return phoenixMessage{}, fmt.Errorf("invalid join_ref : %w", err)
+	}
+	if err := json.Unmarshal(fields[1], &msg.Ref); err != nil {
+		return phoenixMessage{}, fmt.Errorf("invalid ref : %w", err)
+	}
+	if err := json.Unmarshal(fields[2], &msg.Topic); err != nil {
+		return phoenixMessage{}, fmt.Errorf("invalid topic : %w", err)
+	}
+	if err := json.Unmarshal(fields[3], &msg.Event); err != nil {
+		return phoenixMessage{}, fmt.Errorf("invalid event : %w", err)
+	}
+	msg.Payload = fields[4]
+
+	return msg, nil
+}
+
+// phoenixPayload returns payload as JSON, an empty object if it is empty.
+func phoenixPayload(payload string) (json.RawMessage, error) {
+	if payload == "" {
+		return json.RawMessage("{}"), nil
+	}
+
+	if !json.Valid([]byte(payload)) {
+		return nil, fmt.Errorf("payload is not valid JSON")
+	}
+
+	return json.RawMessage(payload), nil
+}
+
+// replyStatus returns the status of a phx_reply payload.
+func replyStatus(payload json.RawMessage) (string, error) {
+	var reply struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(payload, &reply); err != nil {
+		return "", fmt.Errorf("invalid reply payload : %w", err)
+	}
+	return reply.Status, nil
+}
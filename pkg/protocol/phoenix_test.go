@@ -0,0 +1,135 @@
+package protocol
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/gorilla/websocket"
+)
+
+func TestDecodePhoenix(t *testing.T) {
+	msg, err := decodePhoenix([]byte(`["1","2","room:lobby","phx_reply",{"status":"ok","response":{}}]`))
+	if err != nil || *msg.JoinRef != "1" || *msg.Ref != "2" || msg.Topic != "room:lobby" || msg.Event != "phx_reply" {
+		t.Errorf("decodePhoenix() = %+v, %v", msg, err)
+	}
+	if status, err := replyStatus(msg.Payload); status != "ok" || err != nil {
+		t.Errorf("replyStatus() = %q, %v, want ok", status, err)
+	}
+	if _, err := replyStatus(json.RawMessage(`["ok"]`)); err == nil {
+		t.Error("replyStatus() of a payload which is not an object should return error")
+	}
+
+	//a malformed reply is printed as received.
+	message := `["1","1","room:lobby","phx_reply",["ok"]]`
+	if msgs, ok := newPhoenix(nil).Incoming(websocket.TextMessage, []byte(message)); !ok || len(msgs) != 1 || string(msgs[0]) != message {
+		t.Errorf("Incoming() of a malformed reply = %q, %v, want the raw message", msgs, ok)
+	}
+
+	msg, err = decodePhoenix([]byte(`[null,null,"room:lobby","new_msg",{"body":"hi"}]`))
+	if err != nil || msg.JoinRef != nil || msg.Ref != nil || string(msg.Payload) != `{"body":"hi"}` {
+		t.Errorf("decodePhoenix() broadcast = %+v, %v", msg, err)
+	}
+
+	for _, message := range []string{`{"event":"x"}`, `["1","2","t"]`, `["1","2",3,"e",{}]`} {
+		if _, err := decodePhoenix([]byte(message)); err == nil {
+			t.Errorf("decodePhoenix(%s) should return error", message)
+		}
+	}
+}
+
+func TestPhoenixSession(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true, PingInterval: time.Minute, Protocol: config.Protocol{Name: Phoenix, Heartbeat: 50 * time.Millisecond}}
+
+	s := newSession(t, func(conn *websocket.Conn, message []byte) {
+		if message == nil {
+			return
+		}
+
+		msg, err := decodePhoenix(message)
+		if err != nil {
+			t.Errorf("invalid message %s : %v", message, err)
+			return
+		}
+
+		switch msg.Event {
+		case "heartbeat":
+			conn.WriteMessage(websocket.TextMessage, []byte(`[null,"`+*msg.Ref+`","phoenix","phx_reply",{"status":"ok","response":{}}]`))
+		case "phx_join":
+			status := "ok"
+			if msg.Topic == "room:secret" {
+				status = "error"
+			}
+			conn.WriteMessage(websocket.TextMessage, []byte(`["`+*msg.JoinRef+`","`+*msg.Ref+`","`+msg.Topic+`","phx_reply",{"status":"`+status+`","response":{}}]`))
+		case "new_msg":
+			conn.WriteMessage(websocket.TextMessage, []byte(`[null,null,"`+msg.Topic+`","new_msg",`+string(msg.Payload)+`]`))
+		}
+	})
+	defer s.close()
+	s.connect("/socket")
+
+	if got := <-s.requests; got != "/socket/websocket?vsn=2.0.0" {
+		t.Errorf("connected to %s, want /socket/websocket?vsn=2.0.0", got)
+	}
+
+	//skips the heartbeats and other messages sent before the expected event.
+	expectSent := func(event string) phoenixMessage {
+		t.Helper()
+		for {
+			got, err := decodePhoenix([]byte(s.sent()))
+			if err == nil && got.Event == event {
+				return got
+			}
+		}
+	}
+
+	if beat := expectSent("heartbeat"); beat.Topic != phoenixTopic || beat.JoinRef != nil {
+		t.Errorf("heartbeat = %+v", beat)
+	}
+
+	p := s.adapter.(*phoenix)
+	if err := p.joinCmd(`room:lobby {"token":"t"}`); err != nil {
+		t.Fatalf("join() error: %v", err)
+	}
+	join := expectSent("phx_join")
+	if join.Topic != "room:lobby" || *join.JoinRef != *join.Ref || string(join.Payload) != `{"token":"t"}` {
+		t.Errorf("phx_join = %+v", join)
+	}
+	s.expectReceived(`{"join_ref":"` + *join.Ref + `","ref":"` + *join.Ref + `","topic":"room:lobby","event":"phx_reply","payload":{"status":"ok","response":{}}}`)
+
+	if err := p.push(`room:lobby new_msg {"body":"hi"}`); err != nil {
+		t.Fatalf("push() error: %v", err)
+	}
+	if push := expectSent("new_msg"); *push.JoinRef != *join.Ref {
+		t.Errorf("push join_ref = %s, want %s", *push.JoinRef, *join.Ref)
+	}
+	s.expectReceived(`{"topic":"room:lobby","event":"new_msg","payload":{"body":"hi"}}`)
+
+	if err := p.joinCmd("room:secret"); err != nil {
+		t.Fatalf("join() error: %v", err)
+	}
+	expectSent("phx_join")
+	s.message()
+
+	p.mux.Lock()
+	_, lobby := p.joined["room:lobby"]
+	_, secret := p.joined["room:secret"]
+	p.mux.Unlock()
+	if !lobby || secret {
+		t.Errorf("joined lobby = %v, secret = %v, want true, false", lobby, secret)
+	}
+
+	if err := p.leave("room:lobby"); err != nil {
+		t.Fatalf("leave() error: %v", err)
+	}
+	if leave := expectSent("phx_leave"); *leave.JoinRef != *join.Ref {
+		t.Errorf("phx_leave join_ref = %s, want %s", *leave.JoinRef, *join.Ref)
+	}
+
+	if err := p.leave("room:lobby"); err == nil {
+		t.Error("leave() of a topic which is not joined should return error")
+	}
+}
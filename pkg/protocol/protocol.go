@@ -0,0 +1,120 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+)
+
+const (
+	SocketIO    = "socketio"
+	STOMP       = "stomp"
+	GraphQL     = "graphql"
+	JSONRPC     = "jsonrpc"
+	Phoenix     = "phoenix"
+	ActionCable = "actioncable"
+	SignalR     = "signalr"
+)
+
+// Adapter frames an application protocol on top of the WebSocket messages of one client.
+type Adapter interface {
+	ws.Protocol
+
+	// Outgoing converts a line typed, piped or passed with -x to the message to send.
+	Outgoing(line string) (int, []byte, error)
+
+	// Commands returns the slash commands of the protocol. They work without --slash.
+	Commands() []Command
+}
+
+type Command struct {
+	Name    string
+	Usage   string
+	Help    string
+	Handler func(args string) error
+}
+
+// Attach creates the adapter for name and sets it as the protocol of client.
+func Attach(name string, client *ws.Client) (Adapter, error) {
+	var a Adapter
+
+	switch name {
+	case SocketIO:
+		a = newSocketIO(client)
+	case STOMP:
+		a = newStomp(client)
+	case GraphQL:
+		a = newGraphQL(client)
+	case JSONRPC:
+		a = newJSONRPC(client)
+	case Phoenix:
+		a = newPhoenix(client)
+	case ActionCable:
+		a = newActionCable(client)
+	case SignalR:
+		a = newSignalR(client)
+	default:
+		return nil, fmt.Errorf("invalid protocol: %s. Use socketio, stomp, graphql, jsonrpc, phoenix, actioncable or signalr", name)
+	}
+
+	client.SetProtocol(a)
+	return a, nil
+}
+
+// Of returns the adapter of client, false if it has none.
+func Of(client *ws.Client) (Adapter, bool) {
+	a, ok := client.Protocol().(Adapter)
+	return a, ok
+}
+
+// parseArgs parses a sequence of JSON values, e.g. `{"a":1} 2 "x"`. If args is not valid JSON
+// it is returned as a single string so that `emit chat hello` works without quoting.
+func parseArgs(args string) []json.RawMessage {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		return nil
+	}
+
+	dec := json.NewDecoder(strings.NewReader(args))
+	var values []json.RawMessage
+	for dec.More() {
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			str, _ := json.Marshal(args)
+			return []json.RawMessage{str}
+		}
+		values = append(values, v)
+	}
+
+	return values
+}
+
+// splitWord returns the first word of s and the rest.
+func splitWord(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	word, rest, _ := strings.Cut(s, " ")
+	return word, strings.TrimSpace(rest)
+}
+
+// timeout returns how long to wait for handshakes and replies.
+func timeout() time.Duration {
+	if config.Flags.Protocol.Timeout > 0 {
+		return config.Flags.Protocol.Timeout
+	}
+	return 10 * time.Second
+}
+
+func marshal(v any) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return []byte(fmt.Sprintf(`{"error":%q}`, err.Error()))
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
@@ -0,0 +1,127 @@
+package protocol
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+	"github.com/gorilla/websocket"
+)
+
+// session is a client with the protocol of config.Flags attached and the test server it connects to.
+type session struct {
+	t        *testing.T
+	mux      *http.ServeMux //serves the WebSocket on /, other routes can be added before connect.
+	srv      *httptest.Server
+	client   *ws.Client
+	adapter  Adapter
+	requests chan string //request uri of every upgrade request.
+	received chan string //messages received by the server.
+	messages chan string //messages passed to the OnMessage listeners of the client.
+}
+
+// newSession starts a server accepting the first subprotocol requested. handler is called with a nil
+// message once the connection is upgraded, then with every message received from the client.
+func newSession(t *testing.T, handler func(conn *websocket.Conn, message []byte)) *session {
+	t.Helper()
+
+	s := &session{
+		t:        t,
+		mux:      http.NewServeMux(),
+		requests: make(chan string, 10),
+		received: make(chan string, 50),
+		messages: make(chan string, 10),
+	}
+
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		s.requests <- r.URL.RequestURI()
+
+		upgrader := websocket.Upgrader{Subprotocols: websocket.Subprotocols(r)}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		handler(conn, nil)
+
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			s.received <- string(message)
+			handler(conn, message)
+		}
+	})
+	s.srv = httptest.NewServer(s.mux)
+
+	s.client = ws.NewClient()
+	s.client.OnClose(func() {})
+	s.client.OnMessage(func(_ int, message []byte) { s.messages <- string(message) })
+
+	var err error
+	if s.adapter, err = Attach(config.Flags.Protocol.Name, s.client); err != nil {
+		s.srv.Close()
+		t.Fatalf("Attach() error: %v", err)
+	}
+
+	return s
+}
+
+// connect connects the client to path on the server.
+func (s *session) connect(path string) {
+	s.t.Helper()
+
+	if err := s.client.Connect("ws" + strings.TrimPrefix(s.srv.URL, "http") + path); err != nil {
+		s.t.Fatalf("Connect() error: %v", err)
+	}
+}
+
+// close closes the client and the server, it must run before config.Flags is restored.
+func (s *session) close() {
+	s.client.Close()
+	s.srv.Close()
+}
+
+// sent returns the next message received by the server.
+func (s *session) sent() string {
+	s.t.Helper()
+	return s.next(s.received, "sent message")
+}
+
+// message returns the next message passed to the listeners of the client.
+func (s *session) message() string {
+	s.t.Helper()
+	return s.next(s.messages, "received message")
+}
+
+func (s *session) expectSent(want string) {
+	s.t.Helper()
+	if got := s.sent(); got != want {
+		s.t.Errorf("sent %s, want %s", got, want)
+	}
+}
+
+func (s *session) expectReceived(want string) {
+	s.t.Helper()
+	if got := s.message(); got != want {
+		s.t.Errorf("received %s, want %s", got, want)
+	}
+}
+
+func (s *session) next(ch chan string, what string) string {
+	s.t.Helper()
+
+	select {
+	case got := <-ch:
+		return got
+	case <-time.After(2 * time.Second):
+		s.t.Fatalf("timeout waiting for the %s", what)
+		return ""
+	}
+}
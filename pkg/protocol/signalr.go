@@ -0,0 +1,373 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+	"github.com/gorilla/websocket"
+)
+
+// recordSeparator terminates every message of the SignalR JSON hub protocol.
+const recordSeparator = 0x1e
+
+// SignalR hub message types.
+const (
+	srInvocation       = 1
+	srStreamItem       = 2
+	srCompletion       = 3
+	srStreamInvocation = 4
+	srCancelInvocation = 5
+	srPing             = 6
+	srClose            = 7
+	srAck              = 8
+	srSequence         = 9
+)
+
+var signalRTypes = map[int]string{
+	srInvocation:       "invocation",
+	srStreamItem:       "streamItem",
+	srCompletion:       "completion",
+	srStreamInvocation: "streamInvocation",
+	srCancelInvocation: "cancelInvocation",
+	srPing:             "ping",
+	srClose:            "close",
+	srAck:              "ack",
+	srSequence:         "sequence",
+}
+
+// signalR speaks the SignalR JSON hub protocol. The negotiate request is made before connecting,
+// hub methods are called with /invoke and typed lines are sent as raw records.
+type signalR struct {
+	client *ws.Client
+
+	mux         sync.Mutex
+	id          int
+	invocations map[string]string //targets of the invocations waiting for a completion, by invocation id.
+	handshaken  bool              //false until the handshake response of the current connection is received.
+	handshake   chan error        //receives the handshake response.
+}
+
+type signalRNegotiation struct {
+	ConnectionID        string `json:"connectionId"`
+	ConnectionToken     string `json:"connectionToken"`
+	NegotiateVersion    int    `json:"negotiateVersion"`
+	URL                 string `json:"url"`
+	AccessToken         string `json:"accessToken"`
+	Error               string `json:"error"`
+	AvailableTransports []struct {
+		Transport string `json:"transport"`
+	} `json:"availableTransports"`
+}
+
+type signalRMessage struct {
+	Type         int               `json:"type"`
+	InvocationID string            `json:"invocationId,omitempty"`
+	Target       string            `json:"target,omitempty"`
+	Arguments    []json.RawMessage `json:"arguments"`
+}
+
+func newSignalR(client *ws.Client) *signalR {
+	return &signalR{client: client, invocations: map[string]string{}}
+}
+
+// Prepare makes the negotiate request and returns the url with the connection token.
+func (s *signalR) Prepare(connectURL string) (string, []string, error) {
+	s.mux.Lock()
+	s.handshaken = false
+	s.handshake = make(chan error, 1)
+	s.mux.Unlock()
+
+	dialURL, err := negotiate(connectURL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return dialURL, nil, nil
+}
+
+// Connected sends the handshake request and waits for the handshake response.
+func (s *signalR) Connected() error {
+	if err := s.client.Send(websocket.TextMessage, record([]byte(`{"protocol":"json","version":1}`))); err != nil {
+		return err
+	}
+
+	s.mux.Lock()
+	handshake := s.handshake
+	s.mux.Unlock()
+
+	select {
+	case err := <-handshake:
+		return err
+	case <-time.After(timeout()):
+		return fmt.Errorf("signalr handshake response not received within %s", timeout())
+	}
+}
+
+func (s *signalR) Incoming(mt int, message []byte) ([][]byte, bool) {
+	if mt != websocket.TextMessage || !bytes.HasSuffix(message, []byte{recordSeparator}) {
+		return nil, false
+	}
+
+	var msgs [][]byte
+	for _, rec := range bytes.Split(bytes.TrimSuffix(message, []byte{recordSeparator}), []byte{recordSeparator}) {
+		if out, ok := s.incomingRecord(rec); ok {
+			msgs = append(msgs, out)
+		}
+	}
+
+	return msgs, true
+}
+
+// incomingRecord returns how a record is printed, false if it is not printed.
+func (s *signalR) incomingRecord(rec []byte) ([]byte, bool) {
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(rec, &msg); err != nil {
+		return rec, true
+	}
+
+	s.mux.Lock()
+	handshaken := s.handshaken
+	s.handshaken = true
+	s.mux.Unlock()
+
+	if !handshaken {
+		var resp struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(rec, &resp)
+
+		var err error
+		if resp.Error != "" {
+			err = fmt.Errorf("signalr handshake failed : %s", resp.Error)
+		}
+		s.handshakeDone(err)
+		return nil, false
+	}
+
+	var typ int
+	json.Unmarshal(msg["type"], &typ)
+
+	switch typ {
+	case srPing:
+		if err := s.client.Send(websocket.TextMessage, record([]byte(`{"type":6}`))); err != nil {
+			log.Println(err)
+		}
+		if !config.Flags.ShowPingPong {
+			return nil, false
+		}
+	case srCompletion:
+		var id string
+		json.Unmarshal(msg["invocationId"], &id)
+
+		s.mux.Lock()
+		target, ok := s.invocations[id]
+		delete(s.invocations, id)
+		s.mux.Unlock()
+
+		if ok {
+			msg["target"] = marshal(target)
+		}
+	}
+
+	if name, ok := signalRTypes[typ]; ok {
+		msg["type"] = marshal(name)
+	}
+
+	return marshal(msg), true
+}
+
+// Outgoing sends a typed line as a record, the record separator is added if missing.
+func (s *signalR) Outgoing(line string) (int, []byte, error) {
+	return websocket.TextMessage, record([]byte(strings.TrimSuffix(line, string(rune(recordSeparator))))), nil
+}
+
+func (s *signalR) Commands() []Command {
+	return []Command{
+		{"/invoke", "/invoke <method> [args...]", "Invoke a hub method, the arguments are JSON values. The completion is printed with the same invocation id.", s.invokeFunc(srInvocation, true)},
+		{"/send", "/send <method> [args...]", "Invoke a hub method without waiting for a completion.", s.invokeFunc(srInvocation, false)},
+		{"/stream", "/stream <method> [args...]", "Invoke a streaming hub method, the items are printed with the same invocation id.", s.invokeFunc(srStreamInvocation, true)},
+		{"/cancel", "/cancel <invocation id>", "Cancel a stream.", s.cancel},
+	}
+}
+
+func (s *signalR) invokeFunc(typ int, withID bool) func(string) error {
+	return func(args string) error {
+		target, rest := splitWord(args)
+		if target == "" {
+			return fmt.Errorf("method is empty")
+		}
+
+		msg := signalRMessage{Type: typ, Target: target, Arguments: parseArgs(rest)}
+		if msg.Arguments == nil {
+			msg.Arguments = []json.RawMessage{}
+		}
+
+		if withID {
+			s.mux.Lock()
+			s.id++
+			msg.InvocationID = strconv.Itoa(s.id)
+			s.invocations[msg.InvocationID] = target
+			s.mux.Unlock()
+		}
+
+		if err := s.send(msg); err != nil {
+			return err
+		}
+
+		if withID {
+			log.Printf("invoked %s with invocation id %s", target, msg.InvocationID)
+		}
+
+		return nil
+	}
+}
+
+func (s *signalR) cancel(id string) error {
+	if id == "" {
+		return fmt.Errorf("usage: /cancel <invocation id>")
+	}
+
+	s.mux.Lock()
+	delete(s.invocations, id)
+	s.mux.Unlock()
+
+	return s.client.Send(websocket.TextMessage, record(marshal(map[string]any{"type": srCancelInvocation, "invocationId": id})))
+}
+
+func (s *signalR) send(msg signalRMessage) error {
+	return s.client.Send(websocket.TextMessage, record(marshal(msg)))
+}
+
+func (s *signalR) handshakeDone(err error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	select {
+	case s.handshake <- err:
+	default:
+	}
+}
+
+func record(msg []byte) []byte {
+	return append(msg, recordSeparator)
+}
+
+// negotiate makes the negotiate request for the hub at connectURL, following redirects to other
+// services, and returns the WebSocket url with the connection token.
+func negotiate(connectURL string) (string, error) {
+	client, err := ws.HTTPClient()
+	if err != nil {
+		return "", err
+	}
+
+	headers, err := ws.RequestHeaders()
+	if err != nil {
+		return "", err
+	}
+
+	hubURL, accessToken := connectURL, ""
+
+	//like the official clients, redirects are followed at most 100 times.
+	for range 100 {
+		u, err := url.Parse(hubURL)
+		if err != nil {
+			return "", fmt.Errorf("error while parsing the url : %w", err)
+		}
+
+		n, err := negotiateRequest(client, headers, u, accessToken)
+		if err != nil {
+			return "", err
+		}
+
+		if n.URL != "" {
+			hubURL, accessToken = n.URL, n.AccessToken
+			continue
+		}
+
+		if !hasWebSockets(n) {
+			return "", fmt.Errorf("signalr server does not support the WebSockets transport")
+		}
+
+		token := n.ConnectionToken
+		if n.NegotiateVersion == 0 {
+			token = n.ConnectionID
+		}
+
+		q := u.Query()
+		q.Set("id", token)
+		if accessToken != "" {
+			q.Set("access_token", accessToken)
+		}
+		u.RawQuery = q.Encode()
+		u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+
+		return u.String(), nil
+	}
+
+	return "", fmt.Errorf("signalr negotiate redirected too many times")
+}
+
+func negotiateRequest(client *http.Client, headers http.Header, hub *url.URL, accessToken string) (signalRNegotiation, error) {
+	u := *hub
+	u.Scheme = strings.Replace(u.Scheme, "ws", "http", 1)
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/negotiate"
+	q := u.Query()
+	q.Set("negotiateVersion", "1")
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), nil)
+	if err != nil {
+		return signalRNegotiation{}, err
+	}
+
+	req.Header = headers.Clone()
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return signalRNegotiation{}, fmt.Errorf("signalr negotiate error : %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return signalRNegotiation{}, fmt.Errorf("error while reading the negotiate response : %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return signalRNegotiation{}, fmt.Errorf("signalr negotiate returned %s : %s", resp.Status, bytes.TrimSpace(body))
+	}
+
+	var n signalRNegotiation
+	if err := json.Unmarshal(body, &n); err != nil {
+		return signalRNegotiation{}, fmt.Errorf("invalid negotiate response : %w", err)
+	}
+
+	if n.Error != "" {
+		return signalRNegotiation{}, fmt.Errorf("signalr negotiate error : %s", n.Error)
+	}
+
+	return n, nil
+}
+
+func hasWebSockets(n signalRNegotiation) bool {
+	for _, t := range n.AvailableTransports {
+		if t.Transport == "WebSockets" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/gorilla/websocket"
+)
+
+func TestNegotiate(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{Headers: []string{"X-Tenant:a"}}
+
+	var srvURL string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hub/negotiate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Query().Get("negotiateVersion") != "1" || r.Header.Get("X-Tenant") != "a" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"url":"` + srvURL + `/redirected","accessToken":"secret"}`))
+	})
+	mux.HandleFunc("/redirected/negotiate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"connectionId":"cid","connectionToken":"ctoken","negotiateVersion":1,"availableTransports":[{"transport":"WebSockets","transferFormats":["Text","Binary"]}]}`))
+	})
+	mux.HandleFunc("/longpolling/negotiate", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"connectionId":"cid","availableTransports":[{"transport":"LongPolling"}]}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	srvURL = srv.URL
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	got, err := negotiate(wsURL + "/hub")
+	if err != nil {
+		t.Fatalf("negotiate() error: %v", err)
+	}
+	if want := wsURL + "/redirected?access_token=secret&id=ctoken"; got != want {
+		t.Errorf("negotiate() = %s, want %s", got, want)
+	}
+
+	if _, err := negotiate(wsURL + "/longpolling"); err == nil {
+		t.Error("negotiate() without WebSockets transport should return error")
+	}
+
+	if _, err := negotiate(wsURL + "/missing"); err == nil {
+		t.Error("negotiate() with 404 should return error")
+	}
+}
+
+func TestSignalRSession(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true, PingInterval: time.Minute, Protocol: config.Protocol{Name: SignalR}}
+
+	s := newSession(t, func(conn *websocket.Conn, message []byte) {
+		for _, rec := range bytes.Split(bytes.TrimSuffix(message, []byte{recordSeparator}), []byte{recordSeparator}) {
+			var msg map[string]any
+			if json.Unmarshal(rec, &msg) != nil {
+				continue
+			}
+
+			if msg["protocol"] == "json" {
+				//handshake response and a ping in one frame.
+				conn.WriteMessage(websocket.TextMessage, []byte("{}\x1e{\"type\":6}\x1e"))
+				continue
+			}
+
+			if msg["type"] == float64(srInvocation) && msg["invocationId"] != nil {
+				id := msg["invocationId"].(string)
+				conn.WriteMessage(websocket.TextMessage, []byte(`{"type":1,"target":"ReceiveMessage","arguments":["bob","hi"]}`+"\x1e"+`{"type":3,"invocationId":"`+id+`","result":42}`+"\x1e"))
+			}
+		}
+	})
+	defer s.close()
+
+	s.mux.HandleFunc("/chat/negotiate", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"connectionId":"cid","connectionToken":"ctoken","negotiateVersion":1,"availableTransports":[{"transport":"WebSockets"}]}`))
+	})
+	s.connect("/chat")
+
+	if got := <-s.requests; got != "/chat?id=ctoken" {
+		t.Errorf("connected to %s, want the connection token as id", got)
+	}
+
+	//every record sent by the client, the handshake and the ping are sent in one frame.
+	var records []string
+	expectSent := func(want string) {
+		t.Helper()
+		if len(records) == 0 {
+			records = strings.Split(strings.TrimSuffix(s.sent(), "\x1e"), "\x1e")
+		}
+
+		var got map[string]any
+		json.Unmarshal([]byte(records[0]), &got)
+		records = records[1:]
+
+		if b, _ := json.Marshal(got); string(b) != want {
+			t.Errorf("sent %s, want %s", b, want)
+		}
+	}
+
+	expectSent(`{"protocol":"json","version":1}`)
+	expectSent(`{"type":6}`)
+
+	sr := s.adapter.(*signalR)
+	if err := sr.invokeFunc(srInvocation, true)(`SendMessage "bob" {"text":"hi"}`); err != nil {
+		t.Fatalf("invoke() error: %v", err)
+	}
+	expectSent(`{"arguments":["bob",{"text":"hi"}],"invocationId":"1","target":"SendMessage","type":1}`)
+	s.expectReceived(`{"arguments":["bob","hi"],"target":"ReceiveMessage","type":"invocation"}`)
+	s.expectReceived(`{"invocationId":"1","result":42,"target":"SendMessage","type":"completion"}`)
+
+	if err := sr.invokeFunc(srInvocation, false)("Typing"); err != nil {
+		t.Fatalf("send() error: %v", err)
+	}
+	expectSent(`{"arguments":[],"target":"Typing","type":1}`)
+
+	if err := sr.cancel("7"); err != nil {
+		t.Fatalf("cancel() error: %v", err)
+	}
+	expectSent(`{"invocationId":"7","type":5}`)
+
+	select {
+	case got := <-s.messages:
+		t.Errorf("unexpected message %s", got)
+	default:
+	}
+}
@@ -0,0 +1,306 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+	"github.com/gorilla/websocket"
+)
+
+// Engine.IO v4 packet types.
+const (
+	eioOpen    = '0'
+	eioClose   = '1'
+	eioPing    = '2'
+	eioPong    = '3'
+	eioMessage = '4'
+	eioNoop    = '6'
+)
+
+// Socket.IO v5 packet types.
+const (
+	sioConnect      = '0'
+	sioDisconnect   = '1'
+	sioEvent        = '2'
+	sioAck          = '3'
+	sioConnectError = '4'
+	sioBinaryEvent  = '5'
+	sioBinaryAck    = '6'
+)
+
+// socketIO speaks Socket.IO over the Engine.IO v4 WebSocket transport.
+// Typed lines `emit <event> [args...]` are sent as events, received packets are printed as JSON.
+type socketIO struct {
+	client *ws.Client
+
+	mux       sync.Mutex
+	namespace string        //namespace used by emit.
+	ackID     int           //id of the last emit which requested an ack.
+	ready     chan struct{} //closed when the namespace connect is acknowledged.
+}
+
+// sioPacket is how a received packet is printed.
+type sioPacket struct {
+	Type  string          `json:"type"`
+	Nsp   string          `json:"nsp,omitempty"`
+	Event string          `json:"event,omitempty"`
+	ID    *int            `json:"id,omitempty"`
+	Args  json.RawMessage `json:"args,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+func newSocketIO(client *ws.Client) *socketIO {
+	namespace := config.Flags.Protocol.Namespace
+	if namespace == "" {
+		namespace = "/"
+	}
+
+	return &socketIO{client: client, namespace: namespace}
+}
+
+// Prepare adds the Engine.IO query parameters, and the default /socket.io/ path if the url has none.
+func (s *socketIO) Prepare(connectURL string) (string, []string, error) {
+	u, err := url.Parse(connectURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("error while parsing the url : %w", err)
+	}
+
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/socket.io/"
+	}
+
+	q := u.Query()
+	q.Set("EIO", "4")
+	q.Set("transport", "websocket")
+	u.RawQuery = q.Encode()
+
+	s.mux.Lock()
+	s.ready = make(chan struct{})
+	s.mux.Unlock()
+
+	return u.String(), nil, nil
+}
+
+// Connected waits until the server acknowledged the namespace connect, so that -x messages are not lost.
+func (s *socketIO) Connected() error {
+	s.mux.Lock()
+	ready := s.ready
+	s.mux.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-time.After(timeout()):
+		return fmt.Errorf("socket.io namespace not connected within %s", timeout())
+	}
+}
+
+func (s *socketIO) Incoming(mt int, message []byte) ([][]byte, bool) {
+	if mt != websocket.TextMessage || len(message) == 0 {
+		return nil, false
+	}
+
+	switch message[0] {
+	case eioOpen:
+		if err := s.connect(s.currentNamespace()); err != nil {
+			return [][]byte{marshal(sioPacket{Type: "error", Data: marshal(err.Error())})}, true
+		}
+		return [][]byte{marshal(sioPacket{Type: "open", Data: json.RawMessage(message[1:])})}, true
+	case eioPing:
+		if err := s.client.Send(websocket.TextMessage, []byte{eioPong}); err != nil {
+			return [][]byte{marshal(sioPacket{Type: "error", Data: marshal(err.Error())})}, true
+		}
+		if config.Flags.ShowPingPong {
+			return [][]byte{marshal(sioPacket{Type: "ping"})}, true
+		}
+		return nil, true
+	case eioPong, eioNoop:
+		return nil, true
+	case eioClose:
+		return [][]byte{marshal(sioPacket{Type: "close"})}, true
+	case eioMessage:
+		p, err := decodeSocketIO(string(message[1:]))
+		if err != nil {
+			return nil, false
+		}
+
+		if p.Type == "connect" && p.Nsp == s.currentNamespace() {
+			s.markReady()
+		}
+
+		return [][]byte{marshal(p)}, true
+	}
+
+	return nil, false
+}
+
+// Outgoing sends `emit <event> [args...]` and `emitack <event> [args...]` as events, other lines as is.
+func (s *socketIO) Outgoing(line string) (int, []byte, error) {
+	verb, rest := splitWord(line)
+	if verb != "emit" && verb != "emitack" {
+		return websocket.TextMessage, []byte(line), nil
+	}
+
+	event, args := splitWord(rest)
+	if event == "" {
+		return 0, nil, fmt.Errorf("usage: %s <event> [args...]", verb)
+	}
+
+	name, _ := json.Marshal(event)
+	data := append([]json.RawMessage{name}, parseArgs(args)...)
+
+	var sb strings.Builder
+	sb.WriteByte(eioMessage)
+	sb.WriteByte(sioEvent)
+	sb.WriteString(namespacePrefix(s.currentNamespace()))
+
+	if verb == "emitack" {
+		s.mux.Lock()
+		s.ackID++
+		sb.WriteString(strconv.Itoa(s.ackID))
+		s.mux.Unlock()
+	}
+
+	sb.Write(marshal(data))
+
+	return websocket.TextMessage, []byte(sb.String()), nil
+}
+
+func (s *socketIO) Commands() []Command {
+	return []Command{
+		{"/namespace", "/namespace [nsp]", "Connect to a Socket.IO namespace and emit to it, without argument shows the current one.", s.setNamespace},
+	}
+}
+
+func (s *socketIO) setNamespace(nsp string) error {
+	if nsp == "" {
+		log.Println(s.currentNamespace())
+		return nil
+	}
+
+	if !strings.HasPrefix(nsp, "/") {
+		nsp = "/" + nsp
+	}
+
+	s.mux.Lock()
+	s.namespace = nsp
+	s.mux.Unlock()
+
+	return s.connect(nsp)
+}
+
+func (s *socketIO) connect(nsp string) error {
+	packet := string(eioMessage) + string(sioConnect) + namespacePrefix(nsp)
+	if payload := config.Flags.Protocol.Payload; payload != "" {
+		packet += payload
+	}
+
+	return s.client.Send(websocket.TextMessage, []byte(packet))
+}
+
+func (s *socketIO) currentNamespace() string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.namespace
+}
+
+func (s *socketIO) markReady() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.ready == nil {
+		return
+	}
+
+	select {
+	case <-s.ready:
+	default:
+		close(s.ready)
+	}
+}
+
+// namespacePrefix returns the namespace part of a packet, the main namespace is omitted.
+func namespacePrefix(nsp string) string {
+	if nsp == "" || nsp == "/" {
+		return ""
+	}
+	return nsp + ","
+}
+
+// decodeSocketIO decodes a Socket.IO packet: <type>[<attachments>-][<nsp>,][<ack id>][<json data>]
+func decodeSocketIO(packet string) (sioPacket, error) {
+	if packet == "" {
+		return sioPacket{}, fmt.Errorf("empty packet")
+	}
+
+	p := sioPacket{Nsp: "/"}
+	typ, rest := packet[0], packet[1:]
+
+	if typ == sioBinaryEvent || typ == sioBinaryAck {
+		if i := strings.IndexByte(rest, '-'); i >= 0 {
+			rest = rest[i+1:]
+		}
+	}
+
+	if strings.HasPrefix(rest, "/") {
+		i := strings.IndexByte(rest, ',')
+		if i < 0 {
+			p.Nsp, rest = rest, ""
+		} else {
+			p.Nsp, rest = rest[:i], rest[i+1:]
+		}
+	}
+
+	digits := 0
+	for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+		digits++
+	}
+	if digits > 0 {
+		id, err := strconv.Atoi(rest[:digits])
+		if err != nil {
+			return sioPacket{}, fmt.Errorf("invalid ack id : %w", err)
+		}
+		p.ID, rest = &id, rest[digits:]
+	}
+
+	var data json.RawMessage
+	if rest != "" {
+		if !json.Valid([]byte(rest)) {
+			return sioPacket{}, fmt.Errorf("invalid packet data")
+		}
+		data = json.RawMessage(rest)
+	}
+
+	switch typ {
+	case sioConnect:
+		p.Type, p.Data = "connect", data
+	case sioDisconnect:
+		p.Type = "disconnect"
+	case sioEvent, sioBinaryEvent:
+		var args []json.RawMessage
+		if err := json.Unmarshal(data, &args); err != nil || len(args) == 0 {
+			return sioPacket{}, fmt.Errorf("invalid event data")
+		}
+		p.Type = "event"
+		if err := json.Unmarshal(args[0], &p.Event); err != nil {
+			return sioPacket{}, fmt.Errorf("invalid event name : %w", err)
+		}
+		p.Args = marshal(args[1:])
+	case sioAck, sioBinaryAck:
+		p.Type, p.Args = "ack", data
+	case sioConnectError:
+		p.Type, p.Data = "connect_error", data
+	default:
+		return sioPacket{}, fmt.Errorf("unknown packet type %c", typ)
+	}
+
+	return p, nil
+}
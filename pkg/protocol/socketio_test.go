@@ -0,0 +1,132 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+	"github.com/gorilla/websocket"
+)
+
+func TestDecodeSocketIO(t *testing.T) {
+	cases := map[string]string{
+		`0{"sid":"a"}`:                              `{"type":"connect","nsp":"/","data":{"sid":"a"}}`,
+		`0/admin,{"sid":"a"}`:                       `{"type":"connect","nsp":"/admin","data":{"sid":"a"}}`,
+		`1/admin,`:                                  `{"type":"disconnect","nsp":"/admin"}`,
+		`2["chat","hi",{"a":1}]`:                    `{"type":"event","nsp":"/","event":"chat","args":["hi",{"a":1}]}`,
+		`2/admin,12["chat"]`:                        `{"type":"event","nsp":"/admin","event":"chat","id":12,"args":[]}`,
+		`312[{"ok":true}]`:                          `{"type":"ack","nsp":"/","id":12,"args":[{"ok":true}]}`,
+		`4{"message":"Not authorized"}`:             `{"type":"connect_error","nsp":"/","data":{"message":"Not authorized"}}`,
+		`51-["file",{"_placeholder":true,"num":0}]`: `{"type":"event","nsp":"/","event":"file","args":[{"_placeholder":true,"num":0}]}`,
+	}
+
+	for in, want := range cases {
+		p, err := decodeSocketIO(in)
+		if err != nil {
+			t.Errorf("decodeSocketIO(%s) error: %v", in, err)
+			continue
+		}
+		if got := string(marshal(p)); got != want {
+			t.Errorf("decodeSocketIO(%s) = %s, want %s", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"", "2", `2"x"`, `9[]`, `2[1,`} {
+		if _, err := decodeSocketIO(in); err == nil {
+			t.Errorf("decodeSocketIO(%q) should return error", in)
+		}
+	}
+}
+
+func TestSocketIOOutgoing(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{}
+
+	s := newSocketIO(ws.NewClient())
+
+	cases := []struct{ line, want string }{
+		{`emit chat {"a":1} 2`, `42["chat",{"a":1},2]`},
+		{`emit chat hello world`, `42["chat","hello world"]`},
+		{`emit ping`, `42["ping"]`},
+		{`emitack chat "x"`, `421["chat","x"]`},
+		{`emitack chat`, `422["chat"]`},
+		{`41`, `41`},
+	}
+
+	for _, c := range cases {
+		_, got, err := s.Outgoing(c.line)
+		if err != nil || string(got) != c.want {
+			t.Errorf("Outgoing(%q) = %s, %v, want %s", c.line, got, err, c.want)
+		}
+	}
+
+	s.namespace = "/admin"
+	if _, got, _ := s.Outgoing("emit chat 1"); string(got) != `42/admin,["chat",1]` {
+		t.Errorf("Outgoing() in namespace = %s", got)
+	}
+
+	if _, _, err := s.Outgoing("emit"); err == nil {
+		t.Error("Outgoing() without event should return error")
+	}
+}
+
+func TestSocketIOPrepare(t *testing.T) {
+	s := &socketIO{}
+
+	got, _, err := s.Prepare("ws://localhost:3000")
+	if err != nil || got != "ws://localhost:3000/socket.io/?EIO=4&transport=websocket" {
+		t.Errorf("Prepare() = %s, %v", got, err)
+	}
+
+	got, _, _ = s.Prepare("wss://host/custom/?token=a")
+	if got != "wss://host/custom/?EIO=4&token=a&transport=websocket" {
+		t.Errorf("Prepare() with path = %s", got)
+	}
+}
+
+// sioHandler answers like a Socket.IO v4 server: open packet, namespace connect, ping, event echo and acks.
+func sioHandler(conn *websocket.Conn, msg []byte) {
+	packet := string(msg)
+
+	switch {
+	case msg == nil:
+		conn.WriteMessage(websocket.TextMessage, []byte(`0{"sid":"eio","pingInterval":25000,"pingTimeout":20000,"maxPayload":1000000}`))
+	case strings.HasPrefix(packet, "40"):
+		conn.WriteMessage(websocket.TextMessage, []byte(`40{"sid":"sio"}`))
+		conn.WriteMessage(websocket.TextMessage, []byte("2"))
+	case strings.HasPrefix(packet, "421"):
+		conn.WriteMessage(websocket.TextMessage, []byte(`431[{"ok":true}]`))
+	case strings.HasPrefix(packet, "42"):
+		conn.WriteMessage(websocket.TextMessage, msg)
+	}
+}
+
+func TestSocketIOSession(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true, PingInterval: time.Minute, Protocol: config.Protocol{Name: SocketIO, Payload: `{"token":"t"}`}}
+
+	s := newSession(t, sioHandler)
+	defer s.close()
+	s.connect("")
+
+	if got := <-s.requests; got != "/socket.io/?EIO=4&transport=websocket" {
+		t.Errorf("connected to %s, want the Engine.IO path and query", got)
+	}
+
+	s.expectSent(`40{"token":"t"}`)
+	s.expectReceived(`{"type":"open","data":{"sid":"eio","pingInterval":25000,"pingTimeout":20000,"maxPayload":1000000}}`)
+	s.expectReceived(`{"type":"connect","nsp":"/","data":{"sid":"sio"}}`)
+	s.expectSent("3")
+
+	mt, msg, _ := s.adapter.Outgoing(`emitack chat {"text":"hi"}`)
+	if err := s.client.Write(mt, msg); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	s.expectSent(`421["chat",{"text":"hi"}]`)
+	s.expectReceived(`{"type":"ack","nsp":"/","id":1,"args":[{"ok":true}]}`)
+}
@@ -0,0 +1,456 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+	"github.com/gorilla/websocket"
+)
+
+var stompSubprotocols = []string{"v12.stomp", "v11.stomp", "v10.stomp"}
+
+// stomp speaks STOMP 1.2. The CONNECT frame is sent after connecting, frames are sent with the slash
+// commands and typed lines are sent to the destination of the last /send.
+type stomp struct {
+	client *ws.Client
+
+	mux         sync.Mutex
+	host        string
+	subID       int
+	destination string        //destination of the last /send, used for typed lines.
+	connected   chan struct{} //closed when CONNECTED is received.
+	receipt     chan struct{} //closed when the receipt of DISCONNECT is received.
+	stopBeat    chan struct{} //stops the heart-beat of the current connection.
+}
+
+// disconnectReceipt is the receipt header of the DISCONNECT frame.
+const disconnectReceipt = "disconnect"
+
+type stompFrame struct {
+	Command string            `json:"command"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+func newStomp(client *ws.Client) *stomp {
+	return &stomp{client: client}
+}
+
+func (s *stomp) Prepare(connectURL string) (string, []string, error) {
+	u, err := url.Parse(connectURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("error while parsing the url : %w", err)
+	}
+
+	s.mux.Lock()
+	s.host = u.Hostname()
+	s.connected = make(chan struct{})
+	s.mux.Unlock()
+
+	return connectURL, stompSubprotocols, nil
+}
+
+// Connected sends the CONNECT frame and waits for CONNECTED.
+func (s *stomp) Connected() error {
+	beat := config.Flags.Protocol.Heartbeat.Milliseconds()
+
+	headers := map[string]string{
+		"accept-version": "1.2,1.1,1.0",
+		"host":           s.currentHost(),
+		"heart-beat":     fmt.Sprintf("%d,%d", beat, beat),
+	}
+	for key, value := range protocolHeaders() {
+		headers[key] = value
+	}
+
+	if err := s.send("CONNECT", headers, nil); err != nil {
+		return err
+	}
+
+	s.mux.Lock()
+	connected := s.connected
+	s.mux.Unlock()
+
+	select {
+	case <-connected:
+		return nil
+	case <-time.After(timeout()):
+		return fmt.Errorf("stomp CONNECTED not received within %s", timeout())
+	}
+}
+
+func (s *stomp) Incoming(mt int, message []byte) ([][]byte, bool) {
+	if mt != websocket.TextMessage {
+		return nil, false
+	}
+
+	frames, err := decodeStomp(message)
+	if err != nil {
+		return nil, false
+	}
+
+	var msgs [][]byte
+	for _, f := range frames {
+		switch {
+		case f.Command == "CONNECTED":
+			s.startHeartbeat(f.Headers["heart-beat"])
+			s.markConnected()
+		case f.Command == "RECEIPT" && f.Headers["receipt-id"] == disconnectReceipt:
+			s.markDisconnected()
+		}
+		msgs = append(msgs, marshal(f))
+	}
+
+	return msgs, true
+}
+
+// Outgoing sends a typed line as the body of a SEND frame to the destination of the last /send.
+func (s *stomp) Outgoing(line string) (int, []byte, error) {
+	s.mux.Lock()
+	destination := s.destination
+	s.mux.Unlock()
+
+	if destination == "" {
+		return 0, nil, fmt.Errorf("no destination, use /send <destination> <body> first")
+	}
+
+	return websocket.TextMessage, encodeStomp("SEND", sendHeaders(destination, line), []byte(line)), nil
+}
+
+func (s *stomp) Commands() []Command {
+	return []Command{
+		{"/subscribe", "/subscribe <destination> [auto|client|client-individual]", "Subscribe to a destination, the subscription id is printed.", s.subscribe},
+		{"/unsubscribe", "/unsubscribe <id>", "Remove a subscription.", s.unsubscribe},
+		{"/send", "/send <destination> <body>", "Send a message, following typed lines are sent to the same destination.", s.sendMessage},
+		{"/ack", "/ack <ack id>", "Acknowledge a message (the ack header of the MESSAGE frame).", s.ackFunc("ACK")},
+		{"/nack", "/nack <ack id>", "Reject a message (the ack header of the MESSAGE frame).", s.ackFunc("NACK")},
+		{"/disconnect", "/disconnect", "Send DISCONNECT and wait for the receipt.", s.disconnect},
+	}
+}
+
+func (s *stomp) subscribe(args string) error {
+	destination, ack := splitWord(args)
+	if destination == "" {
+		return fmt.Errorf("usage: /subscribe <destination> [auto|client|client-individual]")
+	}
+
+	if ack == "" {
+		ack = "auto"
+	}
+
+	s.mux.Lock()
+	s.subID++
+	id := "sub-" + strconv.Itoa(s.subID)
+	s.mux.Unlock()
+
+	if err := s.send("SUBSCRIBE", map[string]string{"id": id, "destination": destination, "ack": ack}, nil); err != nil {
+		return err
+	}
+
+	log.Printf("subscribed to %s with id %s", destination, id)
+	return nil
+}
+
+func (s *stomp) unsubscribe(id string) error {
+	if id == "" {
+		return fmt.Errorf("usage: /unsubscribe <id>")
+	}
+
+	return s.send("UNSUBSCRIBE", map[string]string{"id": id}, nil)
+}
+
+func (s *stomp) sendMessage(args string) error {
+	destination, body := splitWord(args)
+	if destination == "" {
+		return fmt.Errorf("usage: /send <destination> <body>")
+	}
+
+	s.mux.Lock()
+	s.destination = destination
+	s.mux.Unlock()
+
+	return s.send("SEND", sendHeaders(destination, body), []byte(body))
+}
+
+func (s *stomp) ackFunc(command string) func(string) error {
+	return func(id string) error {
+		if id == "" {
+			return fmt.Errorf("usage: /%s <ack id>", strings.ToLower(command))
+		}
+
+		return s.send(command, map[string]string{"id": id}, nil)
+	}
+}
+
+func (s *stomp) disconnect(string) error {
+	s.stopHeartbeat()
+
+	receipt := make(chan struct{})
+	s.mux.Lock()
+	s.receipt = receipt
+	s.mux.Unlock()
+
+	if err := s.send("DISCONNECT", map[string]string{"receipt": disconnectReceipt}, nil); err != nil {
+		return err
+	}
+
+	select {
+	case <-receipt:
+		log.Println("disconnected, the server acknowledged the DISCONNECT")
+		return nil
+	case <-time.After(timeout()):
+		return fmt.Errorf("stomp DISCONNECT receipt not received within %s", timeout())
+	}
+}
+
+func (s *stomp) send(command string, headers map[string]string, body []byte) error {
+	return s.client.Send(websocket.TextMessage, encodeStomp(command, headers, body))
+}
+
+func (s *stomp) currentHost() string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.host
+}
+
+func (s *stomp) markConnected() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.connected == nil {
+		return
+	}
+
+	select {
+	case <-s.connected:
+	default:
+		close(s.connected)
+	}
+}
+
+func (s *stomp) markDisconnected() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.receipt == nil {
+		return
+	}
+
+	close(s.receipt)
+	s.receipt = nil
+}
+
+// startHeartbeat sends an EOL at the interval negotiated with the server heart-beat header.
+func (s *stomp) startHeartbeat(serverBeat string) {
+	interval := negotiateHeartbeat(config.Flags.Protocol.Heartbeat, serverBeat)
+
+	s.stopHeartbeat()
+	if interval <= 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	s.mux.Lock()
+	s.stopBeat = stop
+	s.mux.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := s.client.Send(websocket.TextMessage, []byte("\n")); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (s *stomp) stopHeartbeat() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.stopBeat != nil {
+		close(s.stopBeat)
+		s.stopBeat = nil
+	}
+}
+
+// negotiateHeartbeat returns the interval at which the client sends heart-beats: the larger of what the
+// client can send and what the server wants to receive, 0 if either side does not want heart-beats.
+func negotiateHeartbeat(client time.Duration, serverBeat string) time.Duration {
+	_, wants, ok := strings.Cut(serverBeat, ",")
+	if !ok {
+		return 0
+	}
+
+	ms, err := strconv.Atoi(strings.TrimSpace(wants))
+	if err != nil || ms <= 0 || client <= 0 {
+		return 0
+	}
+
+	return max(client, time.Duration(ms)*time.Millisecond)
+}
+
+func sendHeaders(destination, body string) map[string]string {
+	headers := map[string]string{"destination": destination}
+	if json.Valid([]byte(body)) {
+		headers["content-type"] = "application/json"
+	} else {
+		headers["content-type"] = "text/plain"
+	}
+	return headers
+}
+
+// protocolHeaders returns the --protocol-header flags as a map.
+func protocolHeaders() map[string]string {
+	headers := map[string]string{}
+	for _, h := range config.Flags.Protocol.Headers {
+		key, value, _ := strings.Cut(h, ":")
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return headers
+}
+
+var (
+	stompEscaper   = strings.NewReplacer("\\", "\\\\", "\r", "\\r", "\n", "\\n", ":", "\\c")
+	stompUnescaper = strings.NewReplacer("\\\\", "\\", "\\r", "\r", "\\n", "\n", "\\c", ":")
+)
+
+// encodeStomp encodes a frame, headers are sorted so that frames are reproducible.
+func encodeStomp(command string, headers map[string]string, body []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(command)
+	buf.WriteByte('\n')
+
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	escape := command != "CONNECT"
+	for _, key := range keys {
+		value := headers[key]
+		if escape {
+			key, value = stompEscaper.Replace(key), stompEscaper.Replace(value)
+		}
+		buf.WriteString(key + ":" + value + "\n")
+	}
+
+	if len(body) > 0 {
+		buf.WriteString("content-length:" + strconv.Itoa(len(body)) + "\n")
+	}
+
+	buf.WriteByte('\n')
+	buf.Write(body)
+	buf.WriteByte(0)
+
+	return buf.Bytes()
+}
+
+// decodeStomp decodes the frames of a message, heart-beats (EOLs) between frames are skipped.
+func decodeStomp(message []byte) ([]stompFrame, error) {
+	var frames []stompFrame
+
+	for {
+		message = bytes.TrimLeft(message, "\r\n")
+		if len(message) == 0 {
+			return frames, nil
+		}
+
+		f, rest, err := decodeStompFrame(message)
+		if err != nil {
+			return nil, err
+		}
+
+		frames = append(frames, f)
+		message = rest
+	}
+}
+
+func decodeStompFrame(message []byte) (stompFrame, []byte, error) {
+	head, rest, ok := cutStompHeaders(message)
+	if !ok {
+		return stompFrame{}, nil, fmt.Errorf("frame without header end")
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(head), "\r\n", "\n"), "\n")
+	f := stompFrame{Command: lines[0], Headers: map[string]string{}}
+
+	unescape := f.Command != "CONNECTED"
+	for _, line := range lines[1:] {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return stompFrame{}, nil, fmt.Errorf("invalid header %q", line)
+		}
+		if unescape {
+			key, value = stompUnescaper.Replace(key), stompUnescaper.Replace(value)
+		}
+		//the first occurrence of a repeated header is used.
+		if _, exists := f.Headers[key]; !exists {
+			f.Headers[key] = value
+		}
+	}
+
+	var body []byte
+	if length, err := strconv.Atoi(f.Headers["content-length"]); err == nil && length >= 0 {
+		if len(rest) <= length || rest[length] != 0 {
+			return stompFrame{}, nil, fmt.Errorf("frame body is not followed by NUL after content-length %d", length)
+		}
+		body, rest = rest[:length], rest[length+1:]
+	} else {
+		i := bytes.IndexByte(rest, 0)
+		if i < 0 {
+			return stompFrame{}, nil, fmt.Errorf("frame without NUL terminator")
+		}
+		body, rest = rest[:i], rest[i+1:]
+	}
+
+	if len(body) > 0 {
+		f.Body = marshalBody(body)
+	}
+
+	return f, rest, nil
+}
+
+// cutStompHeaders splits a frame at the blank line ending its headers, the lines end with LF or CRLF.
+func cutStompHeaders(message []byte) (head, rest []byte, ok bool) {
+	for start := 0; ; {
+		i := bytes.IndexByte(message[start:], '\n')
+		if i < 0 {
+			return nil, nil, false
+		}
+
+		end := start + i
+		if line := message[start:end]; len(line) == 0 || string(line) == "\r" {
+			head = bytes.TrimSuffix(bytes.TrimSuffix(message[:start], []byte("\n")), []byte("\r"))
+			return head, message[end+1:], true
+		}
+
+		start = end + 1
+	}
+}
+
+// marshalBody returns a JSON body as is and other bodies as a JSON string.
+func marshalBody(body []byte) json.RawMessage {
+	if json.Valid(body) {
+		return json.RawMessage(body)
+	}
+	return marshal(string(body))
+}
@@ -0,0 +1,157 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/gorilla/websocket"
+)
+
+func TestEncodeStomp(t *testing.T) {
+	got := encodeStomp("SEND", map[string]string{"destination": "/queue/a", "x-key": "a:b\nc"}, []byte("hi"))
+	want := "SEND\ndestination:/queue/a\nx-key:a\\cb\\nc\ncontent-length:2\n\nhi\x00"
+	if string(got) != want {
+		t.Errorf("encodeStomp() = %q, want %q", got, want)
+	}
+
+	got = encodeStomp("CONNECT", map[string]string{"passcode": "a:b"}, nil)
+	if string(got) != "CONNECT\npasscode:a:b\n\n\x00" {
+		t.Errorf("encodeStomp() CONNECT = %q", got)
+	}
+}
+
+func TestDecodeStomp(t *testing.T) {
+	message := "\nMESSAGE\nsubscription:sub-1\ndestination:/topic/a\\cb\nmessage-id:1\n\n{\"a\":1}\x00\n" +
+		"RECEIPT\nreceipt-id:77\n\n\x00" +
+		"MESSAGE\ncontent-length:5\n\na\x00b c\x00" +
+		"\r\nRECEIPT\r\nreceipt-id:78\r\n\r\n\x00"
+
+	frames, err := decodeStomp([]byte(message))
+	if err != nil {
+		t.Fatalf("decodeStomp() error: %v", err)
+	}
+
+	want := []string{
+		`{"command":"MESSAGE","headers":{"destination":"/topic/a:b","message-id":"1","subscription":"sub-1"},"body":{"a":1}}`,
+		`{"command":"RECEIPT","headers":{"receipt-id":"77"}}`,
+		`{"command":"MESSAGE","headers":{"content-length":"5"},"body":"a\u0000b c"}`,
+		`{"command":"RECEIPT","headers":{"receipt-id":"78"}}`,
+	}
+
+	if len(frames) != len(want) {
+		t.Fatalf("decodeStomp() returned %d frames, want %d", len(frames), len(want))
+	}
+
+	for i, f := range frames {
+		if got := string(marshal(f)); got != want[i] {
+			t.Errorf("frame %d = %s, want %s", i, got, want[i])
+		}
+	}
+
+	if frames, err := decodeStomp([]byte("\n\n")); err != nil || len(frames) != 0 {
+		t.Errorf("decodeStomp() of heart-beats = %v, %v, want no frames", frames, err)
+	}
+
+	if _, err := decodeStomp([]byte("MESSAGE\n\nno terminator")); err == nil {
+		t.Error("decodeStomp() without NUL should return error")
+	}
+
+	for _, message := range []string{"MESSAGE\ncontent-length:2\n\nabc\x00", "MESSAGE\ncontent-length:5\n\nab\x00"} {
+		if _, err := decodeStomp([]byte(message)); err == nil {
+			t.Errorf("decodeStomp(%q) without NUL after the content-length should return error", message)
+		}
+	}
+}
+
+func TestNegotiateHeartbeat(t *testing.T) {
+	cases := []struct {
+		client time.Duration
+		server string
+		want   time.Duration
+	}{
+		{10 * time.Second, "0,20000", 20 * time.Second},
+		{10 * time.Second, "0,5000", 10 * time.Second},
+		{10 * time.Second, "5000,0", 0},
+		{0, "0,5000", 0},
+		{10 * time.Second, "", 0},
+	}
+
+	for _, c := range cases {
+		if got := negotiateHeartbeat(c.client, c.server); got != c.want {
+			t.Errorf("negotiateHeartbeat(%s, %q) = %s, want %s", c.client, c.server, got, c.want)
+		}
+	}
+}
+
+func TestStompSession(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true, PingInterval: time.Minute, Protocol: config.Protocol{
+		Name: STOMP, Headers: []string{"login:guest"}, Heartbeat: 50 * time.Millisecond,
+	}}
+
+	s := newSession(t, func(conn *websocket.Conn, msg []byte) {
+		frame := string(msg)
+		switch {
+		case strings.HasPrefix(frame, "CONNECT\n"):
+			conn.WriteMessage(websocket.TextMessage, []byte("CONNECTED\nversion:1.2\nheart-beat:0,50\n\n\x00"))
+		case strings.HasPrefix(frame, "SEND\n"):
+			conn.WriteMessage(websocket.TextMessage, []byte("MESSAGE\nsubscription:sub-1\nmessage-id:1\ndestination:/topic/chat\n\n{\"text\":\"hi\"}\x00"))
+		case strings.HasPrefix(frame, "DISCONNECT\n"):
+			conn.WriteMessage(websocket.TextMessage, []byte("RECEIPT\nreceipt-id:disconnect\n\n\x00"))
+		}
+	})
+	defer s.close()
+	s.connect("")
+
+	if got := s.client.Conn().Subprotocol(); got != "v12.stomp" {
+		t.Errorf("Subprotocol() = %q, want v12.stomp", got)
+	}
+
+	s.expectSent("CONNECT\naccept-version:1.2,1.1,1.0\nheart-beat:50,50\nhost:127.0.0.1\nlogin:guest\n\n\x00")
+	s.expectReceived(`{"command":"CONNECTED","headers":{"heart-beat":"0,50","version":"1.2"}}`)
+	s.expectSent("\n")
+
+	if _, _, err := s.adapter.Outgoing("hello"); err == nil {
+		t.Error("Outgoing() before /send should return error")
+	}
+
+	commands := map[string]Command{}
+	for _, cmd := range s.adapter.Commands() {
+		commands[cmd.Name] = cmd
+	}
+
+	if err := commands["/send"].Handler(`/app/chat {"text":"hi"}`); err != nil {
+		t.Fatalf("/send error: %v", err)
+	}
+
+	//heart-beats are sent until /disconnect.
+	sentFrame := func() string {
+		t.Helper()
+		for {
+			if got := s.sent(); got != "\n" {
+				return got
+			}
+		}
+	}
+
+	if got, want := sentFrame(), "SEND\ncontent-type:application/json\ndestination:/app/chat\ncontent-length:13\n\n{\"text\":\"hi\"}\x00"; got != want {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+
+	s.expectReceived(`{"command":"MESSAGE","headers":{"destination":"/topic/chat","message-id":"1","subscription":"sub-1"},"body":{"text":"hi"}}`)
+
+	if _, msg, err := s.adapter.Outgoing("plain"); err != nil || !strings.HasPrefix(string(msg), "SEND\ncontent-type:text/plain\ndestination:/app/chat\n") {
+		t.Errorf("Outgoing() after /send = %q, %v", msg, err)
+	}
+
+	if err := commands["/disconnect"].Handler(""); err != nil {
+		t.Fatalf("/disconnect error: %v", err)
+	}
+	if got := sentFrame(); got != "DISCONNECT\nreceipt:disconnect\n\n\x00" {
+		t.Errorf("sent %q, want DISCONNECT with a receipt", got)
+	}
+	s.expectReceived(`{"command":"RECEIPT","headers":{"receipt-id":"disconnect"}}`)
+}
@@ -0,0 +1,200 @@
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	Out = "out" //frame sent to the server.
+	In  = "in"  //frame received from the server.
+)
+
+const base64Encoding = "base64"
+
+// Frame is a single line of the session transcript.
+type Frame struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"`
+	Opcode    string    `json:"opcode"`
+	Size      int       `json:"size"`
+	Payload   string    `json:"payload"`
+	Encoding  string    `json:"encoding,omitempty"` //base64 when the payload is binary.
+	CloseCode int       `json:"close_code,omitempty"`
+	Auto      bool      `json:"auto,omitempty"` //control frame sent by the connection itself, not replayed.
+}
+
+type recorder struct {
+	f   *os.File
+	enc *json.Encoder
+	mux *sync.Mutex
+}
+
+var rec *recorder
+
+// Init starts recording every frame to the file at path. Frames are not recorded until Init is called.
+func Init(path string) (func() error, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error while opening the record file : %w", err)
+	}
+
+	rec = &recorder{
+		f:   f,
+		enc: json.NewEncoder(f),
+		mux: &sync.Mutex{},
+	}
+
+	return f.Close, nil
+}
+
+// Write records a data or control frame. It is a no-op if recording is not enabled.
+func Write(direction string, mt int, payload []byte) {
+	if rec == nil {
+		return
+	}
+
+	rec.write(NewFrame(direction, mt, payload))
+}
+
+// WriteClose records a close frame with its code and reason.
+func WriteClose(direction string, code int, reason string) {
+	if rec == nil {
+		return
+	}
+
+	rec.write(closeFrame(direction, code, reason))
+}
+
+// WriteAuto records a ping or pong frame which was sent automatically, e.g. a keepalive ping or
+// the reply to a ping. Such frames are marked as auto and are not sent again by a replay.
+func WriteAuto(direction string, mt int, payload []byte) {
+	if rec == nil {
+		return
+	}
+
+	fr := NewFrame(direction, mt, payload)
+	fr.Auto = true
+	rec.write(fr)
+}
+
+// WriteCloseAuto records a close frame which was sent automatically, e.g. the reply to a close frame.
+func WriteCloseAuto(direction string, code int, reason string) {
+	if rec == nil {
+		return
+	}
+
+	fr := closeFrame(direction, code, reason)
+	fr.Auto = true
+	rec.write(fr)
+}
+
+func closeFrame(direction string, code int, reason string) Frame {
+	fr := NewFrame(direction, websocket.CloseMessage, []byte(reason))
+	fr.CloseCode = code
+	return fr
+}
+
+func NewFrame(direction string, mt int, payload []byte) Frame {
+	fr := Frame{
+		Time:      time.Now(),
+		Direction: direction,
+		Opcode:    OpcodeName(mt),
+		Size:      len(payload),
+		Payload:   string(payload),
+	}
+
+	if mt == websocket.BinaryMessage || !utf8.Valid(payload) {
+		fr.Payload = base64.StdEncoding.EncodeToString(payload)
+		fr.Encoding = base64Encoding
+	}
+
+	return fr
+}
+
+// Read loads all frames of the transcript at path.
+func Read(path string) ([]Frame, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error while opening the record file : %w", err)
+	}
+	defer f.Close()
+
+	var frames []Frame
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var fr Frame
+		if err := json.Unmarshal(scanner.Bytes(), &fr); err != nil {
+			return nil, fmt.Errorf("invalid frame on line %d : %w", line, err)
+		}
+		frames = append(frames, fr)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error while reading the record file : %w", err)
+	}
+
+	return frames, nil
+}
+
+// Data returns the decoded payload of the frame.
+func (fr Frame) Data() ([]byte, error) {
+	if fr.Encoding == base64Encoding {
+		return base64.StdEncoding.DecodeString(fr.Payload)
+	}
+
+	return []byte(fr.Payload), nil
+}
+
+// MessageType returns the websocket message type of the frame or -1 if the opcode is unknown.
+func (fr Frame) MessageType() int {
+	for mt, name := range opcodeNames {
+		if name == fr.Opcode {
+			return mt
+		}
+	}
+
+	return -1
+}
+
+func (r *recorder) write(fr Frame) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if err := r.enc.Encode(fr); err != nil {
+		logger.Err(err).Msg("error while recording the frame")
+	}
+}
+
+var opcodeNames = map[int]string{
+	websocket.TextMessage:   "text",
+	websocket.BinaryMessage: "binary",
+	websocket.CloseMessage:  "close",
+	websocket.PingMessage:   "ping",
+	websocket.PongMessage:   "pong",
+}
+
+func OpcodeName(mt int) string {
+	if name, ok := opcodeNames[mt]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("opcode(%d)", mt)
+}
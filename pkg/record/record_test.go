@@ -0,0 +1,155 @@
+package record
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/gorilla/websocket"
+)
+
+func TestNewFrame(t *testing.T) {
+	fr := NewFrame(Out, websocket.TextMessage, []byte("hello"))
+	if fr.Opcode != "text" || fr.Payload != "hello" || fr.Size != 5 || fr.Encoding != "" {
+		t.Errorf("NewFrame() text = %+v", fr)
+	}
+
+	bin := []byte{0x00, 0xff, 0x10}
+	fr = NewFrame(In, websocket.BinaryMessage, bin)
+	if fr.Opcode != "binary" || fr.Encoding != "base64" || fr.Size != 3 {
+		t.Errorf("NewFrame() binary = %+v", fr)
+	}
+	if fr.Payload != base64.StdEncoding.EncodeToString(bin) {
+		t.Errorf("NewFrame() binary payload = %q, want base64", fr.Payload)
+	}
+
+	fr = NewFrame(In, websocket.PingMessage, []byte{0xff, 0xfe})
+	if fr.Encoding != "base64" {
+		t.Errorf("NewFrame() with invalid utf8 ping should be base64 encoded, got %+v", fr)
+	}
+}
+
+func TestOpcodeName(t *testing.T) {
+	if got := OpcodeName(websocket.PongMessage); got != "pong" {
+		t.Errorf("OpcodeName(pong) = %q", got)
+	}
+	if got := OpcodeName(3); got != "opcode(3)" {
+		t.Errorf("OpcodeName(3) = %q", got)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	defer func() { rec = nil }()
+
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	closef, err := Init(path)
+	if err != nil {
+		t.Fatalf("Init() error: %v", err)
+	}
+
+	Write(Out, websocket.TextMessage, []byte("hello"))
+	Write(In, websocket.BinaryMessage, []byte{1, 2})
+	WriteClose(In, websocket.CloseNormalClosure, "bye")
+	WriteAuto(Out, websocket.PongMessage, []byte("hb"))
+	WriteCloseAuto(Out, websocket.CloseNormalClosure, "")
+
+	if err := closef(); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var frames []Frame
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var fr Frame
+		if err := json.Unmarshal(scanner.Bytes(), &fr); err != nil {
+			t.Fatalf("invalid json line %q: %v", scanner.Text(), err)
+		}
+		frames = append(frames, fr)
+	}
+
+	if len(frames) != 5 {
+		t.Fatalf("recorded %d frames, want 5", len(frames))
+	}
+	if frames[0].Direction != Out || frames[0].Payload != "hello" {
+		t.Errorf("frame 0 = %+v", frames[0])
+	}
+	if frames[1].Direction != In || frames[1].Opcode != "binary" {
+		t.Errorf("frame 1 = %+v", frames[1])
+	}
+	if frames[2].Opcode != "close" || frames[2].CloseCode != websocket.CloseNormalClosure || frames[2].Payload != "bye" || frames[2].Auto {
+		t.Errorf("frame 2 = %+v", frames[2])
+	}
+	if frames[3].Opcode != "pong" || frames[3].Payload != "hb" || !frames[3].Auto {
+		t.Errorf("frame 3 = %+v, want an auto pong", frames[3])
+	}
+	if frames[4].Opcode != "close" || frames[4].CloseCode != websocket.CloseNormalClosure || !frames[4].Auto {
+		t.Errorf("frame 4 = %+v, want an auto close", frames[4])
+	}
+}
+
+func init() {
+	config.Flags = &config.Flag{}
+	logger.Init(io.Discard, nil)
+}
+
+func TestRead(t *testing.T) {
+	defer func() { rec = nil }()
+
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	closef, err := Init(path)
+	if err != nil {
+		t.Fatalf("Init() error: %v", err)
+	}
+
+	Write(Out, websocket.TextMessage, []byte("hello"))
+	Write(Out, websocket.BinaryMessage, []byte{0xde, 0xad})
+	WriteClose(Out, websocket.CloseGoingAway, "bye")
+	closef()
+
+	frames, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read() error: %v", err)
+	}
+	if len(frames) != 3 {
+		t.Fatalf("Read() returned %d frames, want 3", len(frames))
+	}
+
+	data, err := frames[1].Data()
+	if err != nil || string(data) != "\xde\xad" {
+		t.Errorf("Data() = %x, %v, want dead", data, err)
+	}
+	if mt := frames[1].MessageType(); mt != websocket.BinaryMessage {
+		t.Errorf("MessageType() = %d, want %d", mt, websocket.BinaryMessage)
+	}
+	if mt := frames[2].MessageType(); mt != websocket.CloseMessage {
+		t.Errorf("MessageType() = %d, want %d", mt, websocket.CloseMessage)
+	}
+	if mt := (Frame{Opcode: "unknown"}).MessageType(); mt != -1 {
+		t.Errorf("MessageType() with unknown opcode = %d, want -1", mt)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.jsonl")
+	os.WriteFile(path, []byte("{\"direction\":\"out\"}\nnot json\n"), 0644)
+
+	if _, err := Read(path); err == nil {
+		t.Error("Read() with invalid line should return error")
+	}
+
+	if _, err := Read(filepath.Join(t.TempDir(), "missing.jsonl")); err == nil {
+		t.Error("Read() with missing file should return error")
+	}
+}
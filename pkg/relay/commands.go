@@ -0,0 +1,149 @@
+package relay
+
+import (
+	"log"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+type command struct {
+	name    string
+	usage   string
+	help    string
+	handler func(args string)
+}
+
+func (r *Relay) commands() []command {
+	return []command{
+		{"/server", "/server <message>", "Inject a message toward the upstream, the same as typing it.", r.injectServer},
+		{"/client", "/client <message>", "Inject a message toward the client.", r.injectClient},
+		{"/use", "/use <name>", "Inject the following messages into the named session.", r.use},
+		{"/list", "/list", "List the open sessions.", r.list},
+		{"/rules", "/rules", "List the rewrite rules.", r.listRules},
+		{"/reload", "/reload", "Read the --relay-rules file again.", r.reloadRules},
+		{"/help", "/help", "Show the available commands.", r.help},
+		{"/exit", "/exit", "Exit the application.", nil},
+	}
+}
+
+// Handle runs the command in line or injects line toward the upstream of the active session.
+func (r *Relay) Handle(line string) {
+	for _, cmd := range r.commands() {
+		if cmd.handler != nil && isCommand(line, cmd.name) {
+			cmd.handler(strings.TrimSpace(line[len(cmd.name):]))
+			return
+		}
+	}
+
+	r.injectServer(line)
+}
+
+func (r *Relay) injectServer(message string) {
+	if s := r.current(); s != nil {
+		s.inject(s.upstream, injectToServer, message)
+	}
+}
+
+func (r *Relay) injectClient(message string) {
+	if s := r.current(); s != nil {
+		s.inject(s.client, injectToClient, message)
+	}
+}
+
+// inject writes a text message which was not sent by either side.
+func (s *session) inject(dst *peer, as side, message string) {
+	if err := s.forward(dst, as, websocket.TextMessage, []byte(message)); err != nil {
+		log.Printf("inject error : %s", err)
+		return
+	}
+
+	s.print(as, message)
+}
+
+// current returns the active session, or logs that there is none.
+func (r *Relay) current() *session {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	s := r.sessions[r.active]
+	if s == nil {
+		log.Println("no session, waiting for a client to connect")
+	}
+	return s
+}
+
+func (r *Relay) use(name string) {
+	r.mux.Lock()
+	_, exists := r.sessions[name]
+	if exists {
+		r.active = name
+	}
+	r.mux.Unlock()
+
+	if !exists {
+		log.Printf("session %s does not exist", name)
+	}
+}
+
+func (r *Relay) list(string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	for _, name := range slices.Sorted(maps.Keys(r.sessions)) {
+		marker := " "
+		if name == r.active {
+			marker = "*"
+		}
+		log.Printf("%s %s", marker, name)
+	}
+}
+
+func (r *Relay) listRules(string) {
+	rules := r.rules.Load()
+	if rules == nil || len(rules.Rules) == 0 {
+		log.Println("no rules, use --relay-rules to set them")
+		return
+	}
+
+	for i, rule := range rules.Rules {
+		log.Printf("%d. %s", i+1, rule)
+	}
+}
+
+func (r *Relay) reloadRules(string) {
+	if r.rulesFile == "" {
+		log.Println("no rules file, use --relay-rules to set it")
+		return
+	}
+
+	if err := r.reload(); err != nil {
+		log.Println(err)
+		return
+	}
+
+	log.Printf("%d rules loaded from %s", len(r.rules.Load().Rules), r.rulesFile)
+}
+
+// reload reads the rules file, the rules in use are kept if it is invalid.
+func (r *Relay) reload() error {
+	rules, err := LoadRules(r.rulesFile)
+	if err != nil {
+		return err
+	}
+
+	r.rules.Store(rules)
+	return nil
+}
+
+func (r *Relay) help(string) {
+	for _, cmd := range r.commands() {
+		log.Printf("%-25s %s", cmd.usage, cmd.help)
+	}
+}
+
+func isCommand(line, name string) bool {
+	return line == name || strings.HasPrefix(line, name+" ")
+}
@@ -0,0 +1,377 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/akshaykhairmode/wscli/pkg/record"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+	"github.com/gorilla/websocket"
+)
+
+// closeGrace is how long the other side of a session may take to answer a close frame.
+const closeGrace = time.Second
+
+// handshakeHeaders are set by the dialer itself and are not forwarded to the upstream.
+var handshakeHeaders = map[string]bool{
+	"Host":                     true,
+	"Upgrade":                  true,
+	"Connection":               true,
+	"Sec-Websocket-Key":        true,
+	"Sec-Websocket-Version":    true,
+	"Sec-Websocket-Extensions": true,
+	"Sec-Websocket-Protocol":   true,
+	"Content-Length":           true,
+}
+
+// Relay accepts WebSocket clients and relays every one of them to its own upstream connection,
+// printing and recording the frames in both directions.
+type Relay struct {
+	upstream  string
+	rulesFile string
+	rules     atomic.Pointer[Rules]
+
+	mux      sync.Mutex
+	count    int                 //number of accepted clients, used to name the sessions.
+	sessions map[string]*session //open sessions by name.
+	active   string              //session the typed messages are injected into.
+}
+
+// New returns a relay to upstream. Frames are rewritten with the rules of rulesFile, if it is not empty.
+func New(upstream, rulesFile string) (*Relay, error) {
+	r := &Relay{upstream: upstream, rulesFile: rulesFile, sessions: map[string]*session{}}
+
+	if rulesFile != "" {
+		if err := r.reload(); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// Listen accepts clients on addr, given like --listen (e.g. :8080/ws). The returned function
+// stops accepting, closes the open sessions and waits for them.
+func (r *Relay) Listen(addr string) (ws.CloseFunc, error) {
+	return ws.Serve(addr, r.handle)
+}
+
+// side is where the frames of a session come from.
+type side struct {
+	name      string //client or server, as used by the from field of the rules.
+	label     string //printed before the frames.
+	direction string //record direction of the frames.
+	color     func(format string, a ...any) string
+}
+
+var (
+	fromClient     = side{name: "client", label: "client »", direction: record.Out, color: ws.BlueColor}
+	fromServer     = side{name: "server", label: "server «", direction: record.In, color: ws.GreenColor}
+	injectToServer = side{label: "inject »", direction: record.Out, color: ws.BlueColor}
+	injectToClient = side{label: "inject «", direction: record.In, color: ws.GreenColor}
+)
+
+// peer is one of the two connections of a session. Its writes are serialized because
+// relayed and injected frames are written from different goroutines.
+type peer struct {
+	conn *websocket.Conn
+	mux  sync.Mutex
+}
+
+func (p *peer) write(mt int, message []byte) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.conn.WriteMessage(mt, message)
+}
+
+type session struct {
+	name     string
+	relay    *Relay
+	client   *peer
+	upstream *peer
+
+	closeOnce sync.Once
+	closef    ws.CloseFunc
+	done      chan struct{} //closed when the session is closed, stops the delayed frames.
+}
+
+// handle dials the upstream before upgrading the client, so that the client gets the subprotocol
+// chosen by the upstream and a failed dial is answered with 502.
+func (r *Relay) handle(w http.ResponseWriter, req *http.Request) {
+	r.mux.Lock()
+	r.count++
+	name := "conn-" + strconv.Itoa(r.count)
+	r.mux.Unlock()
+
+	upstreamURL := upstreamURL(r.upstream, req.URL)
+
+	upstream, closeUpstream, err := ws.ConnectWithHeader(upstreamURL, forwardHeaders(req.Header), websocket.Subprotocols(req)...)
+	if err != nil {
+		log.Println(ws.RedColor("[%s] upstream %s", name, err))
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	respHeader := http.Header{}
+	if p := upstream.Subprotocol(); p != "" {
+		respHeader.Set("Sec-WebSocket-Protocol", p)
+	}
+
+	upgrader := websocket.Upgrader{
+		EnableCompression: config.Flags.Compress,
+		CheckOrigin:       func(*http.Request) bool { return true },
+	}
+
+	client, err := upgrader.Upgrade(w, req, respHeader)
+	if err != nil {
+		logger.Debug().Err(err).Msg("upgrade error")
+		closeUpstream()
+		return
+	}
+
+	s := &session{name: name, relay: r, client: &peer{conn: client}, upstream: &peer{conn: upstream}, done: make(chan struct{})}
+	s.closef = func() {
+		closeUpstream()
+		if err := client.Close(); err != nil {
+			logger.Debug().Err(err).Msg("error while closing the client connection")
+		}
+	}
+
+	r.add(s)
+	defer r.remove(name)
+
+	//the context is done when the relay is closed.
+	stop := context.AfterFunc(req.Context(), s.close)
+	defer stop()
+
+	log.Println(ws.GreenColor("[%s] %s connected, relaying to %s", name, req.RemoteAddr, upstreamURL))
+
+	s.run()
+}
+
+// add registers a session and makes it the active one.
+func (r *Relay) add(s *session) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.sessions[s.name] = s
+	r.active = s.name
+}
+
+// remove forgets a closed session, the most recent remaining one becomes active.
+func (r *Relay) remove(name string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	delete(r.sessions, name)
+	if r.active != name {
+		return
+	}
+
+	r.active = ""
+	for n, s := range r.sessions {
+		if r.active == "" || s.number() > r.sessions[r.active].number() {
+			r.active = n
+		}
+	}
+}
+
+// run relays the frames until one of the sides is closed. After a close frame the other side
+// gets closeGrace to answer it, after an error both connections are closed at once.
+func (s *session) run() {
+	s.handleControl(s.client, s.upstream, fromClient)
+	s.handleControl(s.upstream, s.client, fromServer)
+
+	done := make(chan error, 2)
+	go func() { done <- s.pump(s.client, s.upstream, fromClient) }()
+	go func() { done <- s.pump(s.upstream, s.client, fromServer) }()
+
+	<-done
+
+	select {
+	case <-done:
+		s.close()
+	case <-time.After(closeGrace):
+		s.close()
+		<-done
+	}
+
+	log.Printf("[%s] disconnected", s.name)
+}
+
+func (s *session) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.closef()
+	})
+}
+
+// number returns the number in the name of the session, e.g. 2 for conn-2.
+func (s *session) number() int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(s.name, "conn-"))
+	return n
+}
+
+// pump writes the text and binary frames read from src to dst, after applying the rewrite rules.
+// A delayed frame also delays the frames read after it.
+func (s *session) pump(src, dst *peer, from side) error {
+	for {
+		mt, message, err := src.conn.ReadMessage()
+		if err != nil {
+			if !isClose(err) {
+				s.close()
+			}
+			return err
+		}
+
+		s.print(from, formatFrame(mt, message))
+
+		rewritten, delay, drop := s.relay.rules.Load().Apply(from.name, message)
+		if drop {
+			s.print(from, "(dropped)")
+			continue
+		}
+
+		if !bytes.Equal(rewritten, message) {
+			message = rewritten
+			s.print(from, "(rewritten) "+formatFrame(mt, message))
+		}
+
+		if delay > 0 {
+			s.print(from, "(delayed "+delay.String()+")")
+			if !s.wait(delay) {
+				return net.ErrClosed
+			}
+		}
+
+		if err := s.forward(dst, from, mt, message); err != nil {
+			logger.Debug().Err(err).Msgf("%s write error", s.name)
+			s.close()
+			return err
+		}
+	}
+}
+
+// wait waits for the delay of a frame, it returns false if the session is closed meanwhile.
+func (s *session) wait(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// forward writes a frame and records it as sent from side.
+func (s *session) forward(dst *peer, from side, mt int, message []byte) error {
+	if err := dst.write(mt, message); err != nil {
+		return err
+	}
+
+	record.Write(from.direction, mt, message)
+	return nil
+}
+
+// handleControl forwards the ping, pong and close frames read from src to dst, instead of
+// answering them, so that both ends see each other's control frames.
+func (s *session) handleControl(src, dst *peer, from side) {
+	forward := func(mt int, data []byte) error {
+		err := dst.conn.WriteControl(mt, data, time.Now().Add(3*time.Second))
+		if err != nil && !errors.Is(err, websocket.ErrCloseSent) {
+			logger.Debug().Err(err).Msgf("%s control write error", s.name)
+		}
+		return nil
+	}
+
+	src.conn.SetPingHandler(func(data string) error {
+		if config.Flags.ShowPingPong {
+			s.print(from, "ping "+data)
+		}
+		record.Write(from.direction, websocket.PingMessage, []byte(data))
+		return forward(websocket.PingMessage, []byte(data))
+	})
+
+	src.conn.SetPongHandler(func(data string) error {
+		if config.Flags.ShowPingPong {
+			s.print(from, "pong "+data)
+		}
+		record.Write(from.direction, websocket.PongMessage, []byte(data))
+		return forward(websocket.PongMessage, []byte(data))
+	})
+
+	src.conn.SetCloseHandler(func(code int, text string) error {
+		s.print(from, strings.TrimSpace("close "+strconv.Itoa(code)+" "+text))
+		record.WriteClose(from.direction, code, text)
+
+		msg := []byte{}
+		if code != websocket.CloseNoStatusReceived {
+			msg = websocket.FormatCloseMessage(code, text)
+		}
+		return forward(websocket.CloseMessage, msg)
+	})
+}
+
+func (s *session) print(from side, line string) {
+	log.Println(ws.BlueColor("[%s]", s.name) + " " + from.color("%s %s", from.label, line))
+}
+
+// formatFrame shows binary frames as hex.
+func formatFrame(mt int, message []byte) string {
+	if mt == websocket.BinaryMessage {
+		return hex.EncodeToString(message)
+	}
+	return string(message)
+}
+
+func isClose(err error) bool {
+	var closeErr *websocket.CloseError
+	return errors.As(err, &closeErr)
+}
+
+// upstreamURL adds the query of the client request to the upstream url, the parameters of
+// the upstream url take precedence.
+func upstreamURL(upstream string, client *url.URL) string {
+	if client.RawQuery == "" {
+		return upstream
+	}
+
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return upstream
+	}
+
+	q := client.Query()
+	for k, v := range u.Query() {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+// forwardHeaders returns the client request headers which are sent to the upstream, e.g. cookies and authorization.
+func forwardHeaders(h http.Header) http.Header {
+	forwarded := http.Header{}
+	for k, v := range h {
+		if !handshakeHeaders[http.CanonicalHeaderKey(k)] {
+			forwarded[k] = v
+		}
+	}
+	return forwarded
+}
@@ -0,0 +1,259 @@
+package relay
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/akshaykhairmode/wscli/pkg/record"
+	"github.com/gorilla/websocket"
+)
+
+func TestUpstreamURL(t *testing.T) {
+	tests := []struct {
+		upstream, client, want string
+	}{
+		{"ws://example.com/ws", "/ws", "ws://example.com/ws"},
+		{"ws://example.com/ws", "/ws?token=abc", "ws://example.com/ws?token=abc"},
+		{"ws://example.com/ws?token=up", "/ws?token=abc&room=1", "ws://example.com/ws?room=1&token=up"},
+	}
+
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.client)
+		if got := upstreamURL(tt.upstream, u); got != tt.want {
+			t.Errorf("upstreamURL(%q, %q) = %q, want %q", tt.upstream, tt.client, got, tt.want)
+		}
+	}
+}
+
+func TestForwardHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("Cookie", "session=1")
+	h.Set("Sec-WebSocket-Key", "abc")
+	h.Set("Upgrade", "websocket")
+
+	got := forwardHeaders(h)
+	if got.Get("Cookie") != "session=1" {
+		t.Errorf("Cookie = %q, want it forwarded", got.Get("Cookie"))
+	}
+	if got.Get("Sec-WebSocket-Key") != "" || got.Get("Upgrade") != "" {
+		t.Errorf("handshake headers forwarded: %v", got)
+	}
+}
+
+func TestRelay(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true, PingInterval: time.Minute}
+
+	recordFile := filepath.Join(t.TempDir(), "session.jsonl")
+	closeRecord, err := record.Init(recordFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeRecord()
+
+	upgrader := websocket.Upgrader{Subprotocols: []string{"chat"}}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteMessage(websocket.TextMessage, []byte("cookie="+r.Header.Get("Cookie")+" token="+r.URL.Query().Get("token")))
+		for {
+			mt, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(mt, append([]byte("echo "), message...))
+		}
+	}))
+	defer upstream.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	r, err := New("ws"+strings.TrimPrefix(upstream.URL, "http"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closef, err := r.Listen(addr)
+	if err != nil {
+		t.Fatalf("Listen() error: %v", err)
+	}
+	defer closef()
+
+	dialer := websocket.Dialer{Subprotocols: []string{"chat"}}
+	conn, _, err := dialer.Dial("ws://"+addr+"/?token=abc", http.Header{"Cookie": {"session=1"}})
+	if err != nil {
+		t.Fatalf("Dial() error: %v", err)
+	}
+	defer conn.Close()
+
+	if conn.Subprotocol() != "chat" {
+		t.Errorf("Subprotocol() = %q, want the one chosen by the upstream", conn.Subprotocol())
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	if _, got, err := conn.ReadMessage(); err != nil || string(got) != "cookie=session=1 token=abc" {
+		t.Errorf("first message = %q, %v, want the forwarded cookie and query", got, err)
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+	if _, got, err := conn.ReadMessage(); err != nil || string(got) != "echo hello" {
+		t.Errorf("reply = %q, %v, want %q", got, err, "echo hello")
+	}
+
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye"))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("ReadMessage() after close = %v, want the close answered by the upstream", err)
+	}
+
+	frames, err := record.Read(recordFile)
+	if err != nil {
+		t.Fatalf("record.Read() error: %v", err)
+	}
+
+	var directions []string
+	for _, fr := range frames {
+		directions = append(directions, fr.Direction+":"+fr.Opcode)
+	}
+
+	want := "in:text,out:text,in:text,out:close,in:close"
+	if got := strings.Join(directions, ","); got != want {
+		t.Errorf("recorded frames = %s, want %s", got, want)
+	}
+}
+
+func TestRelayRulesAndInject(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true, PingInterval: time.Minute}
+	logger.Init(io.Discard, nil)
+
+	rulesFile := filepath.Join(t.TempDir(), "rules.yaml")
+	rules := `
+rules:
+  - from: client
+    match: 'token=\w+'
+    replace: 'token=xxx'
+  - from: server
+    json: '.type == "noise"'
+    drop: true
+  - from: server
+    json: '.type == "price"'
+    jq: '.price = 0'
+  - from: client
+    match: '^slow'
+    delay: 1h
+`
+	if err := os.WriteFile(rulesFile, []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan string, 10)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			mt, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- string(message)
+			conn.WriteMessage(mt, message)
+		}
+	}))
+	defer upstream.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	r, err := New("ws"+strings.TrimPrefix(upstream.URL, "http"), rulesFile)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	closef, err := r.Listen(addr)
+	if err != nil {
+		t.Fatalf("Listen() error: %v", err)
+	}
+	defer closef()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr, nil)
+	if err != nil {
+		t.Fatalf("Dial() error: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	read := func() string {
+		t.Helper()
+		_, got, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage() error: %v", err)
+		}
+		return string(got)
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte("login token=abc"))
+	if got := <-received; got != "login token=xxx" {
+		t.Errorf("upstream received %q, want the token replaced", got)
+	}
+	if got := read(); got != "login token=xxx" {
+		t.Errorf("client received %q, want the echo of the rewritten message", got)
+	}
+
+	//the echo of the noise message is dropped on its way back to the client.
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"noise"}`))
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"price","price":100}`))
+	if got := read(); got != `{"price":0,"type":"price"}` {
+		t.Errorf("client received %q, want the price rewritten and the noise dropped", got)
+	}
+	<-received
+	<-received
+
+	r.Handle("/client injected")
+	if got := read(); got != "injected" {
+		t.Errorf("client received %q, want the injected message", got)
+	}
+
+	r.Handle("typed line")
+	if got := <-received; got != "typed line" {
+		t.Errorf("upstream received %q, want the typed line", got)
+	}
+	if got := read(); got != "typed line" {
+		t.Errorf("client received %q, want the echo of the typed line", got)
+	}
+
+	//closing the relay stops the delay of the pending frame.
+	conn.WriteMessage(websocket.TextMessage, []byte("slow message"))
+	time.Sleep(100 * time.Millisecond)
+}
@@ -0,0 +1,194 @@
+package relay
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/filter"
+	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/itchyny/gojq"
+	"gopkg.in/yaml.v3"
+)
+
+// Rules rewrite, drop and delay the relayed frames, see the relay section of the README.
+type Rules struct {
+	Rules []*Rule `yaml:"rules"` //applied in order, every matching rule is applied.
+}
+
+// Rule applies to the frames from From matching both Match and JSON, a rule without both matches every frame.
+type Rule struct {
+	From    string        `yaml:"from"`    //client or server, both when empty.
+	Match   string        `yaml:"match"`   //regular expression.
+	JSON    string        `yaml:"json"`    //jq expression, matches if it returns a value other than null or false.
+	Replace *string       `yaml:"replace"` //replaces the matches of Match, $1 is the first submatch.
+	JQ      string        `yaml:"jq"`      //jq expression whose first value replaces a JSON frame, e.g. .price = 0.
+	Drop    bool          `yaml:"drop"`
+	Delay   time.Duration `yaml:"delay"`
+
+	regex  *regexp.Regexp
+	filter *filter.Filter
+	jq     *filter.Filter
+}
+
+func LoadRules(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading the rules : %w", err)
+	}
+
+	return ParseRules(data)
+}
+
+func ParseRules(data []byte) (*Rules, error) {
+	var r Rules
+	if err := yaml.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("error while unmarshalling the rules : %w", err)
+	}
+
+	for i, rule := range r.Rules {
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("rule %d : %w", i+1, err)
+		}
+	}
+
+	return &r, nil
+}
+
+func (r *Rule) compile() error {
+	var err error
+
+	switch r.From {
+	case "", "client", "server":
+	default:
+		return fmt.Errorf("invalid from: %s. Use client or server", r.From)
+	}
+
+	if r.Match != "" {
+		if r.regex, err = regexp.Compile(r.Match); err != nil {
+			return fmt.Errorf("invalid match : %w", err)
+		}
+	}
+
+	if r.JSON != "" {
+		if r.filter, err = filter.New(r.JSON); err != nil {
+			return err
+		}
+	}
+
+	if r.JQ != "" {
+		if r.jq, err = filter.New(r.JQ); err != nil {
+			return err
+		}
+	}
+
+	if r.Replace != nil && r.regex == nil {
+		return fmt.Errorf("replace needs a match expression")
+	}
+
+	if r.Replace == nil && r.jq == nil && !r.Drop && r.Delay <= 0 {
+		return fmt.Errorf("rule needs replace, jq, drop or delay")
+	}
+
+	return nil
+}
+
+// Apply returns the frame from side from rewritten by the matching rules, how long to delay it
+// and whether to drop it. Rules see the frame as rewritten by the rules before them.
+func (rs *Rules) Apply(from string, message []byte) ([]byte, time.Duration, bool) {
+	if rs == nil {
+		return message, 0, false
+	}
+
+	var delay time.Duration
+
+	for _, r := range rs.Rules {
+		if !r.matches(from, message) {
+			continue
+		}
+
+		if r.Drop {
+			return nil, 0, true
+		}
+
+		delay += r.Delay
+
+		if r.Replace != nil {
+			message = r.regex.ReplaceAll(message, []byte(*r.Replace))
+		}
+
+		if r.jq != nil {
+			message = r.rewrite(message)
+		}
+	}
+
+	return message, delay, false
+}
+
+func (r *Rule) matches(from string, message []byte) bool {
+	if r.From != "" && r.From != from {
+		return false
+	}
+
+	if r.regex != nil && !r.regex.Match(message) {
+		return false
+	}
+
+	if r.filter != nil {
+		values, err := r.filter.Values(message)
+		if err != nil || !filter.Truthy(values) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// rewrite replaces message with the first value of the jq expression, a message which is not JSON is kept.
+func (r *Rule) rewrite(message []byte) []byte {
+	values, err := r.jq.Values(message)
+	if err != nil || len(values) == 0 {
+		logger.Debug().Err(err).Msg("jq rewrite error")
+		return message
+	}
+
+	enc, err := gojq.Marshal(values[0])
+	if err != nil {
+		logger.Debug().Err(err).Msg("error while marshalling the jq output")
+		return message
+	}
+
+	return enc
+}
+
+// String describes the rule for /rules, e.g. from server json '.type == "price"' jq '.price = 0'.
+func (r *Rule) String() string {
+	var parts []string
+
+	if r.From != "" {
+		parts = append(parts, "from "+r.From)
+	}
+	if r.Match != "" {
+		parts = append(parts, "match "+strconv.Quote(r.Match))
+	}
+	if r.JSON != "" {
+		parts = append(parts, "json '"+r.JSON+"'")
+	}
+	if r.Replace != nil {
+		parts = append(parts, "replace "+strconv.Quote(*r.Replace))
+	}
+	if r.JQ != "" {
+		parts = append(parts, "jq '"+r.JQ+"'")
+	}
+	if r.Drop {
+		parts = append(parts, "drop")
+	}
+	if r.Delay > 0 {
+		parts = append(parts, "delay "+r.Delay.String())
+	}
+
+	return strings.Join(parts, " ")
+}
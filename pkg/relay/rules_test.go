@@ -0,0 +1,74 @@
+package relay
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   string
+		wantErr bool
+	}{
+		{"valid", "rules:\n  - match: a\n    replace: b\n  - json: .x\n    delay: 1s", false},
+		{"invalid from", "rules:\n  - from: both\n    drop: true", true},
+		{"replace without match", "rules:\n  - replace: b", true},
+		{"no action", "rules:\n  - match: a", true},
+		{"invalid jq", "rules:\n  - jq: '.x ='", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRules([]byte(tt.rules))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRulesApply(t *testing.T) {
+	rules, err := ParseRules([]byte(`
+rules:
+  - from: server
+    match: '"status":"(\w+)"'
+    replace: '"status":"error","was":"$1"'
+  - from: server
+    json: '.type == "order"'
+    delay: 2s
+  - from: client
+    match: ^ping$
+    drop: true
+  - json: .price
+    jq: .price = -1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		from, message, want string
+		delay               time.Duration
+		drop                bool
+	}{
+		{"server", `{"type":"order","status":"ok"}`, `{"type":"order","status":"error","was":"ok"}`, 2 * time.Second, false},
+		{"client", `{"status":"ok"}`, `{"status":"ok"}`, 0, false},
+		{"client", "ping", "", 0, true},
+		{"server", "ping", "ping", 0, false},
+		{"client", `{"price":5,"n":1.50}`, `{"n":1.50,"price":-1}`, 0, false},
+		{"client", "not json", "not json", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, delay, drop := rules.Apply(tt.from, []byte(tt.message))
+		if string(got) != tt.want || delay != tt.delay || drop != tt.drop {
+			t.Errorf("Apply(%s, %s) = %s, %s, %t, want %s, %s, %t", tt.from, tt.message, got, delay, drop, tt.want, tt.delay, tt.drop)
+		}
+	}
+
+	var none *Rules
+	if got, _, _ := none.Apply("client", []byte("hi")); string(got) != "hi" {
+		t.Errorf("nil rules Apply() = %s, want the message as is", got)
+	}
+}
@@ -0,0 +1,278 @@
+package script
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/filter"
+	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+	"github.com/gorilla/websocket"
+	"github.com/itchyny/gojq"
+)
+
+const (
+	Send       = "send"        //send <message>
+	Expect     = "expect"      //expect <regex>
+	ExpectJSON = "expect-json" //expect-json <jq path> [json value]
+	Timeout    = "timeout"     //timeout <duration>, used by the following expect steps.
+	Sleep      = "sleep"       //sleep <duration>
+)
+
+const (
+	defaultTimeout  = 5 * time.Second
+	messageChanSize = 1000
+)
+
+type Step struct {
+	Line int
+	Kind string
+	Arg  string
+
+	regex    *regexp.Regexp
+	filter   *filter.Filter
+	value    any  //expected value of an expect-json step.
+	hasValue bool //false if the expect-json step only checks that the path is not null or false.
+	dur      time.Duration
+}
+
+func Load(path string) ([]Step, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error while opening the script : %w", err)
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// Parse reads one step per line. Empty lines and lines starting with # are ignored.
+func Parse(r io.Reader) ([]Step, error) {
+
+	var steps []Step
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		kind, arg, _ := strings.Cut(text, " ")
+		step := Step{Line: line, Kind: kind, Arg: strings.TrimSpace(arg)}
+
+		if err := step.compile(); err != nil {
+			return nil, fmt.Errorf("line %d : %w", line, err)
+		}
+
+		steps = append(steps, step)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error while reading the script : %w", err)
+	}
+
+	return steps, nil
+}
+
+func (s *Step) compile() error {
+	var err error
+
+	switch s.Kind {
+	case Send:
+		if s.Arg == "" {
+			return fmt.Errorf("send requires a message")
+		}
+	case Expect:
+		if s.regex, err = regexp.Compile(s.Arg); err != nil {
+			return fmt.Errorf("invalid regex : %w", err)
+		}
+	case ExpectJSON:
+		path, value, _ := strings.Cut(s.Arg, " ")
+		if s.filter, err = filter.New(path); err != nil {
+			return err
+		}
+
+		if value = strings.TrimSpace(value); value != "" {
+			dec := json.NewDecoder(strings.NewReader(value))
+			dec.UseNumber()
+			if err := dec.Decode(&s.value); err != nil {
+				return fmt.Errorf("invalid expected json value %s : %w", value, err)
+			}
+			s.hasValue = true
+		}
+	case Timeout, Sleep:
+		if s.dur, err = time.ParseDuration(s.Arg); err != nil {
+			return fmt.Errorf("invalid duration : %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown step %q", s.Kind)
+	}
+
+	return nil
+}
+
+func (s Step) String() string {
+	return s.Kind + " " + s.Arg
+}
+
+// Runner executes steps against a client.
+type Runner struct {
+	client   *ws.Client
+	messages chan []byte
+}
+
+// NewRunner listens to the messages of client. It must be called before the client connects,
+// otherwise the messages sent by the server on connect are missed by the expect steps.
+func NewRunner(client *ws.Client) *Runner {
+	r := &Runner{client: client, messages: make(chan []byte, messageChanSize)}
+
+	client.OnMessage(func(mt int, message []byte) {
+		if mt == websocket.BinaryMessage {
+			message = []byte(hex.EncodeToString(message))
+		}
+
+		select {
+		case r.messages <- message:
+		default:
+			logger.Debug().Msg("script message buffer is full, dropping message")
+		}
+	})
+
+	return r
+}
+
+// Run executes the steps. The returned error describes the first expectation which
+// was not met along with the messages received while waiting for it.
+func (r *Runner) Run(steps []Step) error {
+
+	timeout := defaultTimeout
+
+	for i, step := range steps {
+		switch step.Kind {
+		case Send:
+			if err := r.client.Write(websocket.TextMessage, []byte(step.Arg)); err != nil {
+				return fmt.Errorf("step %d (line %d) %s failed : %w", i+1, step.Line, step, err)
+			}
+		case Timeout:
+			timeout = step.dur
+		case Sleep:
+			time.Sleep(step.dur)
+		case Expect, ExpectJSON:
+			if received, ok := expect(step, r.messages, timeout); !ok {
+				return fmt.Errorf("step %d (line %d) %s not met within %s\n%s", i+1, step.Line, step, timeout, diff(step, received))
+			}
+		}
+
+		log.Println(ws.GreenColor("✓ %s", step))
+	}
+
+	return nil
+}
+
+// expect consumes messages until one matches the step or the timeout expires.
+// It returns the messages which did not match.
+func expect(step Step, messages <-chan []byte, timeout time.Duration) ([][]byte, bool) {
+
+	var received [][]byte
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case msg := <-messages:
+			if step.match(msg) {
+				return received, true
+			}
+			received = append(received, msg)
+		case <-timer.C:
+			return received, false
+		}
+	}
+}
+
+func (s Step) match(message []byte) bool {
+	if s.Kind == Expect {
+		return s.regex.Match(message)
+	}
+
+	values, err := s.filter.Values(message)
+	if err != nil {
+		return false
+	}
+
+	if !s.hasValue {
+		return filter.Truthy(values)
+	}
+
+	for _, v := range values {
+		if gojq.Compare(v, s.value) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func diff(step Step, received [][]byte) string {
+
+	var sb bytes.Buffer
+
+	switch {
+	case step.Kind == Expect:
+		fmt.Fprintf(&sb, "  expected : message matching %s\n", step.Arg)
+	case step.hasValue:
+		enc, _ := gojq.Marshal(step.value)
+		fmt.Fprintf(&sb, "  expected : %s == %s\n", step.filter, enc)
+	default:
+		fmt.Fprintf(&sb, "  expected : %s to be present and not false\n", step.filter)
+	}
+
+	if len(received) == 0 {
+		sb.WriteString("  received : no messages")
+		return sb.String()
+	}
+
+	fmt.Fprintf(&sb, "  received : %d messages", len(received))
+	for _, msg := range received {
+		fmt.Fprintf(&sb, "\n    - %s", msg)
+		if step.Kind == ExpectJSON {
+			fmt.Fprintf(&sb, "  (%s = %s)", step.filter, actual(step.filter, msg))
+		}
+	}
+
+	return sb.String()
+}
+
+func actual(f *filter.Filter, message []byte) string {
+	values, err := f.Values(message)
+	if err != nil {
+		return err.Error()
+	}
+
+	var out []string
+	for _, v := range values {
+		enc, err := gojq.Marshal(v)
+		if err != nil {
+			continue
+		}
+		out = append(out, string(enc))
+	}
+
+	if len(out) == 0 {
+		return "empty"
+	}
+
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,164 @@
+package script
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+	"github.com/gorilla/websocket"
+)
+
+func TestParse(t *testing.T) {
+	src := `
+# comment
+timeout 2s
+send {"op":"ping"}
+expect ^pong$
+expect-json .type "ack"
+expect-json .id
+sleep 10ms
+`
+	steps, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	if len(steps) != 6 {
+		t.Fatalf("Parse() returned %d steps, want 6", len(steps))
+	}
+
+	if steps[0].Kind != Timeout || steps[0].dur != 2*time.Second || steps[0].Line != 3 {
+		t.Errorf("step 0 = %+v", steps[0])
+	}
+	if steps[1].Kind != Send || steps[1].Arg != `{"op":"ping"}` {
+		t.Errorf("step 1 = %+v", steps[1])
+	}
+	if !steps[3].hasValue || steps[4].hasValue {
+		t.Errorf("expect-json hasValue = %v, %v, want true, false", steps[3].hasValue, steps[4].hasValue)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []string{
+		"unknown step",
+		"send",
+		"expect (",
+		"expect-json .a |",
+		"expect-json .a {invalid",
+		"timeout soon",
+	}
+
+	for _, src := range cases {
+		if _, err := Parse(strings.NewReader(src)); err == nil {
+			t.Errorf("Parse(%q) should return error", src)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	steps, err := Parse(strings.NewReader(`expect ^pong
+expect-json .type "ack"
+expect-json .id
+expect-json .n 1`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		step    int
+		message string
+		want    bool
+	}{
+		{0, "pong 1", true},
+		{0, "ping", false},
+		{1, `{"type":"ack"}`, true},
+		{1, `{"type":"nack"}`, false},
+		{1, `not json`, false},
+		{2, `{"id":5}`, true},
+		{2, `{"id":null}`, false},
+		{3, `{"n":1.0}`, true},
+	}
+
+	for _, c := range cases {
+		if got := steps[c.step].match([]byte(c.message)); got != c.want {
+			t.Errorf("%s match(%q) = %v, want %v", steps[c.step], c.message, got, c.want)
+		}
+	}
+}
+
+func TestExpect(t *testing.T) {
+	steps, err := Parse(strings.NewReader(`expect-json .type "ack"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := make(chan []byte, 3)
+	messages <- []byte(`{"type":"hb"}`)
+	messages <- []byte(`{"type":"ack"}`)
+
+	received, ok := expect(steps[0], messages, time.Second)
+	if !ok {
+		t.Fatal("expect() = false, want true")
+	}
+	if len(received) != 1 {
+		t.Errorf("expect() skipped %d messages, want 1", len(received))
+	}
+
+	messages <- []byte(`{"type":"nack"}`)
+	received, ok = expect(steps[0], messages, 20*time.Millisecond)
+	if ok {
+		t.Fatal("expect() = true after timeout, want false")
+	}
+
+	d := diff(steps[0], received)
+	if !strings.Contains(d, `.type == "ack"`) || !strings.Contains(d, `(.type = "nack")`) {
+		t.Errorf("diff() = %q, want expected and actual values", d)
+	}
+}
+
+func TestRunGreeting(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true, PingInterval: time.Minute}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteMessage(websocket.TextMessage, []byte("welcome"))
+		conn.ReadMessage()
+	}))
+	defer srv.Close()
+
+	steps, err := Parse(strings.NewReader("timeout 2s\nexpect ^welcome$"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the greeting is sent on connect, before Run is called.
+	client := ws.NewClient()
+	runner := NewRunner(client)
+	if err := client.Connect("ws" + strings.TrimPrefix(srv.URL, "http")); err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+	defer client.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	if err := runner.Run(steps); err != nil {
+		t.Errorf("Run() error: %v", err)
+	}
+}
+
+func init() {
+	config.Flags = &config.Flag{}
+	logger.Init(io.Discard, nil)
+}
@@ -41,7 +41,9 @@ func New() (*Term, CloseFunc, *sync.WaitGroup) {
 	log.SetOutput(term.GetOutLoc())
 	log.SetFlags(0)
 
-	log.Println(ws.GreenColor("Connected"))
+	if config.Flags.Listen == "" {
+		log.Println(ws.GreenColor("Connected"))
+	}
 
 	return term, rl.Close, wg
 }
@@ -20,8 +20,25 @@ var completer = readline.NewPrefixCompleter(
 	readline.PcItem("/help"),
 	readline.PcItem("/flags"),
 	readline.PcItem("/print"),
+	readline.PcItem("/close"),
+	readline.PcItem("/bfile"),
+	readline.PcItem("/filter"),
+	readline.PcItem("/open"),
+	readline.PcItem("/use"),
+	readline.PcItem("/list"),
+	readline.PcItem("/closeconn"),
+	readline.PcItem("/set"),
 )
 
+// AddCompletions adds commands to the tab completion.
+func AddCompletions(names ...string) {
+	children := completer.GetChildren()
+	for _, name := range names {
+		children = append(children, readline.PcItem(name))
+	}
+	completer.SetChildren(children)
+}
+
 func getDefaultConfig() *readline.Config {
 	return &readline.Config{
 		Prompt:          getPrompt("» "),
@@ -0,0 +1,96 @@
+package tmplfunc
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FuncMap is the set of functions available in message templates, e.g. {{RandomNum 100}}.
+var FuncMap = template.FuncMap{
+	"RandomNum":   randomInt,
+	"RandomUUID":  randomUUID,
+	"RandomAN":    randomAlphaNumeric,
+	"UniqSeq":     getUniqueSequence,
+	"Array":       array,
+	"RandomArray": randomArray,
+	"Now":         now,
+	"UnixMilli":   unixMilli,
+}
+
+var arrayCounter = &atomic.Uint64{}
+
+var uniqueSequenceMap = &sync.Map{}
+
+const alphaNumericChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func array(elems ...string) string {
+	return elems[arrayCounter.Add(1)%uint64(len(elems))]
+}
+
+func randomArray(elems ...string) string {
+	return elems[rand.Intn(len(elems))]
+}
+
+func getUniqueSequence(group string, start ...uint64) uint64 {
+
+	val, _ := uniqueSequenceMap.LoadOrStore(group, getUint64Counter(start...))
+	tc := val.(*atomic.Uint64)
+
+	newVal := tc.Add(1)
+
+	return newVal - 1
+}
+
+func getUint64Counter(start ...uint64) *atomic.Uint64 {
+	c := &atomic.Uint64{}
+	if len(start) <= 0 || start[0] <= 0 {
+		return c
+	}
+
+	c.Store(start[0])
+	return c
+}
+
+func randomAlphaNumeric(length ...int) string {
+	l := 10
+	if len(length) > 0 {
+		l = length[0]
+	}
+
+	b := make([]byte, l)
+	for i := range b {
+		b[i] = alphaNumericChars[rand.Intn(len(alphaNumericChars))]
+	}
+
+	return string(b)
+}
+
+func randomInt(max ...int) int {
+	if len(max) <= 0 {
+		return rand.Intn(10000)
+	}
+
+	return rand.Intn(max[0])
+}
+
+func randomUUID() string {
+	guid := uuid.New()
+	return guid.String()
+}
+
+func now(layout ...string) string {
+	if len(layout) > 0 {
+		return time.Now().Format(layout[0])
+	}
+
+	return time.Now().Format(time.RFC3339)
+}
+
+func unixMilli() int64 {
+	return time.Now().UnixMilli()
+}
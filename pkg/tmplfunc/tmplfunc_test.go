@@ -0,0 +1,112 @@
+package tmplfunc
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRandomInt(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		val := randomInt(10)
+		if val < 0 || val >= 10 {
+			t.Errorf("randomInt(10) = %d, want [0, 10)", val)
+		}
+	}
+
+	val := randomInt()
+	if val < 0 || val >= 10000 {
+		t.Errorf("randomInt() = %d, want [0, 10000)", val)
+	}
+}
+
+func TestRandomAlphaNumeric(t *testing.T) {
+	got := randomAlphaNumeric(20)
+	if len(got) != 20 {
+		t.Errorf("randomAlphaNumeric(20) len = %d, want 20", len(got))
+	}
+
+	got = randomAlphaNumeric()
+	if len(got) != 10 {
+		t.Errorf("randomAlphaNumeric() len = %d, want 10", len(got))
+	}
+}
+
+func TestRandomUUID(t *testing.T) {
+	uuid := randomUUID()
+	if len(uuid) != 36 {
+		t.Errorf("randomUUID() len = %d, want 36", len(uuid))
+	}
+}
+
+func TestNow(t *testing.T) {
+	if got := now("2006"); len(got) != 4 {
+		t.Errorf("now(2006) = %q, want a year", got)
+	}
+
+	if _, err := time.Parse(time.RFC3339, now()); err != nil {
+		t.Errorf("now() is not RFC3339: %v", err)
+	}
+
+	if unixMilli() <= 0 {
+		t.Error("unixMilli() <= 0")
+	}
+}
+
+func TestArray(t *testing.T) {
+	elems := []string{"a", "b", "c"}
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		val := array(elems...)
+		seen[val] = true
+	}
+	for _, e := range elems {
+		if !seen[e] {
+			t.Errorf("array() never returned %q", e)
+		}
+	}
+}
+
+func TestRandomArray(t *testing.T) {
+	elems := []string{"a", "b", "c"}
+	for i := 0; i < 100; i++ {
+		val := randomArray(elems...)
+		found := false
+		for _, e := range elems {
+			if val == e {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("randomArray() returned unexpected value %q", val)
+		}
+	}
+}
+
+func TestGetUint64Counter(t *testing.T) {
+	c := getUint64Counter()
+	if c.Load() != 0 {
+		t.Errorf("getUint64Counter() = %d, want 0", c.Load())
+	}
+
+	c = getUint64Counter(42)
+	if c.Load() != 42 {
+		t.Errorf("getUint64Counter(42) = %d, want 42", c.Load())
+	}
+
+	c = getUint64Counter(0)
+	if c.Load() != 0 {
+		t.Errorf("getUint64Counter(0) = %d, want 0", c.Load())
+	}
+}
+
+func TestGetUniqueSequence(t *testing.T) {
+	uniqueSequenceMap = &sync.Map{}
+
+	val1 := getUniqueSequence("test")
+	val2 := getUniqueSequence("test")
+	if val1 != 0 || val2 != 1 {
+		t.Errorf("getUniqueSequence() returned %d, %d, want 0, 1", val1, val2)
+	}
+}
@@ -0,0 +1,222 @@
+package tunnel
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/akshaykhairmode/wscli/pkg/ws"
+	"github.com/gorilla/websocket"
+)
+
+// bufferSize is the largest chunk of the byte stream carried by one binary frame.
+const bufferSize = 32 * 1024
+
+var count atomic.Int64
+
+// Forward accepts connections on local, a TCP address or unix:<path>, and carries the byte stream
+// of every one of them over its own WebSocket connection to connectURL.
+// The returned function stops accepting, closes the open tunnels and waits for them.
+func Forward(local, connectURL string) (ws.CloseFunc, error) {
+	network, address := splitNetwork(local)
+
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("listen error : %w", err)
+	}
+
+	var (
+		mux     sync.Mutex
+		open    = map[net.Conn]struct{}{}
+		tunnels sync.WaitGroup
+	)
+
+	accepting := make(chan struct{})
+	go func() {
+		defer close(accepting)
+
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.Println(ws.RedColor("accept error : %s", err))
+				}
+				return
+			}
+
+			mux.Lock()
+			open[nc] = struct{}{}
+			mux.Unlock()
+
+			tunnels.Add(1)
+			go func() {
+				defer tunnels.Done()
+				forward(nc, connectURL)
+
+				mux.Lock()
+				delete(open, nc)
+				mux.Unlock()
+			}()
+		}
+	}()
+
+	return func() {
+		if err := ln.Close(); err != nil {
+			logger.Debug().Err(err).Msg("error while closing the listener")
+		}
+		<-accepting
+
+		mux.Lock()
+		for nc := range open {
+			closeNetConn(nc)
+		}
+		mux.Unlock()
+
+		tunnels.Wait()
+	}, nil
+}
+
+func forward(nc net.Conn, connectURL string) {
+	name := newName()
+
+	conn, closeConn, err := ws.Connect(connectURL)
+	if err != nil {
+		log.Println(ws.RedColor("[%s] %s", name, err))
+		closeNetConn(nc)
+		return
+	}
+	defer closeConn()
+
+	log.Println(ws.GreenColor("[%s] %s connected, forwarding to %s", name, remoteAddr(nc), connectURL))
+	pipe(name, conn, nc)
+}
+
+// Reverse accepts WebSocket connections on addr, given like --listen (e.g. :8080/ws), and pipes
+// every one of them to its own connection to target, a TCP address or unix:<path>.
+// The returned function stops accepting, closes the open tunnels and waits for them.
+func Reverse(addr, target string) (ws.CloseFunc, error) {
+	upgrader := websocket.Upgrader{
+		Subprotocols:      slices.Clone(config.Flags.SubProtocol),
+		EnableCompression: config.Flags.Compress,
+		CheckOrigin:       func(*http.Request) bool { return true },
+	}
+
+	network, address := splitNetwork(target)
+
+	return ws.Serve(addr, func(w http.ResponseWriter, r *http.Request) {
+		name := newName()
+
+		nc, err := net.DialTimeout(network, address, 30*time.Second)
+		if err != nil {
+			log.Println(ws.RedColor("[%s] %s", name, err))
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			logger.Debug().Err(err).Msg("upgrade error")
+			closeNetConn(nc)
+			return
+		}
+		defer ws.KeepAlive(r.Context(), conn)()
+
+		log.Println(ws.GreenColor("[%s] %s connected, forwarding to %s", name, r.RemoteAddr, target))
+		pipe(name, conn, nc)
+	})
+}
+
+// pipe copies the byte stream of nc to conn as binary frames and the payload of every frame
+// received on conn to nc, until one of them is closed. The connection to nc is closed when
+// the WebSocket is, and a close frame is sent when nc reaches EOF.
+func pipe(name string, conn *websocket.Conn, nc net.Conn) {
+	var sent, received atomic.Int64
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		buf := make([]byte, bufferSize)
+		for {
+			n, err := nc.Read(buf)
+			if n > 0 {
+				if err := conn.WriteMessage(websocket.BinaryMessage, buf[:n]); err != nil {
+					logger.Debug().Err(err).Msgf("%s write error", name)
+					return
+				}
+				sent.Add(int64(n))
+			}
+
+			if err != nil {
+				if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+					logger.Debug().Err(err).Msgf("%s read error", name)
+				}
+
+				msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+				if err := conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(3*time.Second)); err != nil {
+					logger.Debug().Err(err).Msgf("%s close error", name)
+				}
+				return
+			}
+		}
+	}()
+
+	for {
+		_, r, err := conn.NextReader()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logger.Debug().Err(err).Msgf("%s read error", name)
+			}
+			break
+		}
+
+		n, err := io.Copy(nc, r)
+		received.Add(n)
+		if err != nil {
+			logger.Debug().Err(err).Msgf("%s write error", name)
+			break
+		}
+	}
+
+	closeNetConn(nc)
+	<-done
+
+	log.Printf("[%s] closed, %d bytes sent, %d bytes received", name, sent.Load(), received.Load())
+}
+
+func newName() string {
+	return "tunnel-" + strconv.FormatInt(count.Add(1), 10)
+}
+
+// splitNetwork splits unix:/tmp/app.sock into unix and /tmp/app.sock, other addresses are tcp.
+func splitNetwork(addr string) (string, string) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		return "unix", path
+	}
+	return "tcp", addr
+}
+
+// remoteAddr returns the address of the peer, unix socket peers have none.
+func remoteAddr(nc net.Conn) string {
+	if addr := nc.RemoteAddr(); addr != nil && addr.String() != "" {
+		return addr.String()
+	}
+	return nc.LocalAddr().String()
+}
+
+func closeNetConn(nc net.Conn) {
+	if err := nc.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		logger.Debug().Err(err).Msg("error while closing the connection")
+	}
+}
@@ -0,0 +1,142 @@
+package tunnel
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/logger"
+)
+
+func TestSplitNetwork(t *testing.T) {
+	tests := []struct {
+		addr, network, address string
+	}{
+		{"localhost:5432", "tcp", "localhost:5432"},
+		{":8080", "tcp", ":8080"},
+		{"unix:/tmp/app.sock", "unix", "/tmp/app.sock"},
+	}
+
+	for _, tt := range tests {
+		network, address := splitNetwork(tt.addr)
+		if network != tt.network || address != tt.address {
+			t.Errorf("splitNetwork(%q) = %q, %q, want %q, %q", tt.addr, network, address, tt.network, tt.address)
+		}
+	}
+}
+
+// TestForwardReverse chains both modes: a TCP client connects to Forward, which carries the stream
+// over a WebSocket to Reverse, which pipes it to a TCP echo server.
+func TestForwardReverse(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true, PingInterval: time.Minute}
+	logger.Init(io.Discard, nil)
+
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+
+	go func() {
+		for {
+			nc, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer nc.Close()
+				io.Copy(nc, nc)
+			}()
+		}
+	}()
+
+	wsAddr := freeAddr(t)
+	closeReverse, err := Reverse(wsAddr+"/tunnel", echo.Addr().String())
+	if err != nil {
+		t.Fatalf("Reverse() error: %v", err)
+	}
+	defer closeReverse()
+
+	local := "unix:" + filepath.Join(t.TempDir(), "forward.sock")
+	closeForward, err := Forward(local, "ws://"+wsAddr+"/tunnel")
+	if err != nil {
+		t.Fatalf("Forward() error: %v", err)
+	}
+	defer closeForward()
+
+	nc, err := net.Dial("unix", local[len("unix:"):])
+	if err != nil {
+		t.Fatalf("Dial() error: %v", err)
+	}
+	defer nc.Close()
+
+	nc.SetDeadline(time.Now().Add(2 * time.Second))
+	reader := bufio.NewReader(nc)
+
+	for _, line := range []string{"hello\n", "second line\n"} {
+		if _, err := nc.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+
+		got, err := reader.ReadString('\n')
+		if err != nil || got != line {
+			t.Errorf("echo = %q, %v, want %q", got, err, line)
+		}
+	}
+
+	//closing the client closes the tunnel and the connection to the echo server.
+	nc.(*net.UnixConn).CloseWrite()
+	if _, err := reader.ReadByte(); err != io.EOF {
+		t.Errorf("ReadByte() after close = %v, want EOF", err)
+	}
+}
+
+func TestReverseDialError(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true, PingInterval: time.Minute}
+	logger.Init(io.Discard, nil)
+
+	wsAddr := freeAddr(t)
+	closeReverse, err := Reverse(wsAddr, "unix:"+filepath.Join(t.TempDir(), "missing.sock"))
+	if err != nil {
+		t.Fatalf("Reverse() error: %v", err)
+	}
+	defer closeReverse()
+
+	local := freeAddr(t)
+	closeForward, err := Forward(local, "ws://"+wsAddr)
+	if err != nil {
+		t.Fatalf("Forward() error: %v", err)
+	}
+	defer closeForward()
+
+	nc, err := net.Dial("tcp", local)
+	if err != nil {
+		t.Fatalf("Dial() error: %v", err)
+	}
+	defer nc.Close()
+
+	nc.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := nc.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Read() = %v, want EOF when the target cannot be reached", err)
+	}
+}
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	return ln.Addr().String()
+}
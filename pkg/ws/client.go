@@ -0,0 +1,365 @@
+package ws
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/global"
+	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/gorilla/websocket"
+)
+
+// Client owns the connection of an interactive or pipe session. The underlying
+// connection can be replaced with Connect without leaving the session.
+type Client struct {
+	mux         sync.RWMutex
+	conn        *websocket.Conn
+	closef      CloseFunc
+	url         string
+	name        string
+	onReconnect func()
+	onClose     func()
+	listeners   []func(mt int, message []byte)
+	protocol    Protocol
+	accepted    bool           //accepted by Listen, not redialed when the connection drops.
+	writeMux    sync.Mutex     //gorilla connections support one concurrent writer.
+	reading     sync.WaitGroup //read goroutines, waited for by Close.
+	closing     chan struct{}  //closed by Close to stop a reconnect in progress.
+	closeSent   bool           //a close frame was sent with WriteControl, the connection is not redialed.
+
+	stampMux    sync.Mutex
+	connectedAt time.Time
+	lastMessage time.Time
+
+	pendingMux sync.Mutex
+	pending    map[string]*pendingRequest //sent messages waiting for a reply, by --correlate id.
+	timers     sync.WaitGroup             //timers of the pending messages, waited for by Close.
+}
+
+func NewClient() *Client {
+	return &Client{}
+}
+
+// Connect dials connectURL and replaces the current connection with the new one.
+// The current connection is kept if the dial fails.
+func (c *Client) Connect(connectURL string) error {
+
+	conn, closef, err := c.dial(connectURL)
+	if err != nil {
+		return err
+	}
+
+	c.mux.Lock()
+	old, oldClose := c.conn, c.closef
+	c.conn, c.closef, c.url = conn, closef, connectURL
+	c.closeSent = false
+	if c.closing == nil {
+		c.closing = make(chan struct{})
+	}
+	c.mux.Unlock()
+
+	c.resetStamp()
+
+	closeConn(old, oldClose)
+
+	c.goRead(conn)
+
+	c.connected()
+
+	return nil
+}
+
+func (c *Client) Conn() *websocket.Conn {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.conn
+}
+
+func (c *Client) URL() string {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.url
+}
+
+func (c *Client) Name() string {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.name
+}
+
+// SetName sets the name used to tag the messages received on this client.
+func (c *Client) SetName(name string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.name = name
+}
+
+// OnClose registers f to be called when the connection drops and is not re-established.
+// Without it the application is stopped.
+func (c *Client) OnClose(f func()) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.onClose = f
+}
+
+// OnReconnect registers f to be called every time the connection is re-established after a drop.
+func (c *Client) OnReconnect(f func()) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.onReconnect = f
+}
+
+// OnMessage registers f to be called with every text and binary message received.
+func (c *Client) OnMessage(f func(mt int, message []byte)) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.listeners = append(c.listeners, f)
+}
+
+func (c *Client) notify(mt int, message []byte) {
+	c.mux.RLock()
+	listeners := c.listeners
+	c.mux.RUnlock()
+
+	for _, f := range listeners {
+		f(mt, message)
+	}
+}
+
+func (c *Client) Write(mt int, message []byte) error {
+	return c.write(mt, message, WriteToServer)
+}
+
+// WriteRaw sends a text or binary frame as is, without --binary and --encode, e.g. a recorded frame.
+func (c *Client) WriteRaw(mt int, message []byte) error {
+	return c.write(mt, message, WriteMessage)
+}
+
+func (c *Client) write(mt int, message []byte, send func(conn *websocket.Conn, mt int, message []byte) error) error {
+	untrack := c.track(mt, message)
+
+	c.writeMux.Lock()
+	err := send(c.Conn(), mt, message)
+	c.writeMux.Unlock()
+	if err != nil {
+		untrack()
+		return err
+	}
+
+	if config.Flags.Timestamp != "" {
+		c.println(BlueColor("%s %s", outMarker, formatSent(mt, message)))
+	}
+
+	return nil
+}
+
+// WriteControl sends a control frame. Ping and pong frames are printed like the received ones
+// with --show-ping-pong. After a close frame the connection is not redialed with --reconnect.
+func (c *Client) WriteControl(mt int, data []byte) error {
+	conn := c.Conn()
+	if conn == nil {
+		return fmt.Errorf("connection is nil")
+	}
+
+	//set before writing, the reply of the server may be read before the write returns.
+	if mt == websocket.CloseMessage {
+		c.setCloseSent(true)
+	}
+
+	if err := WriteControl(conn, mt, data); err != nil {
+		if mt == websocket.CloseMessage {
+			c.setCloseSent(false)
+		}
+		return fmt.Errorf("write error : %w", err)
+	}
+
+	if mt == websocket.CloseMessage || !config.Flags.ShowPingPong && config.Flags.Timestamp == "" {
+		return nil
+	}
+
+	what := "ping"
+	if mt == websocket.PongMessage {
+		what = "pong"
+	}
+	c.println(BlueColor("%s", marked(outMarker, fmt.Sprintf("sent %s (data: %s)", what, data))))
+
+	return nil
+}
+
+// Close closes the connection, stops the --correlate timers and waits for the read goroutine,
+// so it must not be called from the OnMessage, OnClose and OnReconnect callbacks.
+// The client can be connected again.
+func (c *Client) Close() {
+	c.mux.Lock()
+	conn, closef := c.conn, c.closef
+	c.conn, c.closef = nil, nil
+	if c.closing != nil {
+		close(c.closing)
+		c.closing = nil
+	}
+	c.mux.Unlock()
+
+	closeConn(conn, closef)
+
+	c.reading.Wait()
+	c.stopPending()
+}
+
+func (c *Client) setCloseSent(sent bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.closeSent = sent
+}
+
+func (c *Client) isCurrent(conn *websocket.Conn) bool {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.conn == conn
+}
+
+func (c *Client) goRead(conn *websocket.Conn) {
+	c.reading.Add(1)
+	go func() {
+		defer c.reading.Done()
+		c.read(conn)
+	}()
+}
+
+func (c *Client) read(conn *websocket.Conn) {
+
+	err := c.readMessages(conn)
+
+	//connection was replaced or closed by us, the session continues.
+	if !c.isCurrent(conn) {
+		return
+	}
+
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Println(err.Error())
+	}
+
+	c.mux.RLock()
+	closeSent := c.closeSent
+	c.mux.RUnlock()
+
+	//the connection was closed with /close, it is not redialed.
+	if config.Flags.Reconnect.Enabled && !c.accepted && !closeSent && c.reconnect(conn) {
+		return
+	}
+
+	c.mux.RLock()
+	onClose := c.onClose
+	c.mux.RUnlock()
+
+	if onClose != nil {
+		onClose()
+		return
+	}
+
+	logger.Debug().Msg("enabling global stop application flag")
+	global.Stop()
+}
+
+// reconnect redials the current url until it succeeds or the attempts are exhausted.
+// It returns false if the session should be stopped.
+func (c *Client) reconnect(old *websocket.Conn) bool {
+
+	rc := config.Flags.Reconnect
+
+	c.mux.RLock()
+	closing := c.closing
+	c.mux.RUnlock()
+
+	for attempt := uint(1); rc.Attempts == 0 || attempt <= rc.Attempts; attempt++ {
+
+		delay := backoff(attempt, rc.Delay, rc.MaxDelay)
+		log.Printf("connection lost, reconnecting in %s (attempt %d)", delay.Round(time.Millisecond), attempt)
+
+		select {
+		case <-time.After(delay):
+		case <-closing:
+			return true
+		}
+
+		if !c.isCurrent(old) {
+			return true
+		}
+
+		conn, closef, err := c.dial(c.URL())
+		if err != nil {
+			log.Printf("reconnect attempt %d failed : %s", attempt, err)
+			continue
+		}
+
+		c.mux.Lock()
+		if c.conn != old {
+			//closed or replaced by /connect while we were waiting.
+			c.mux.Unlock()
+			closef()
+			return true
+		}
+		oldClose := c.closef
+		c.conn, c.closef = conn, closef
+		onReconnect := c.onReconnect
+		c.mux.Unlock()
+
+		oldClose()
+
+		c.resetStamp()
+		log.Println(GreenColor("Reconnected"))
+
+		c.goRead(conn)
+
+		c.connected()
+
+		if onReconnect != nil {
+			onReconnect()
+		}
+
+		return true
+	}
+
+	log.Printf("giving up after %d reconnect attempts", rc.Attempts)
+	return false
+}
+
+// backoff returns the delay before the given attempt, doubling base on every attempt up to max,
+// a max of 0 or less does not cap it. Half of the delay is randomised so that many clients do not
+// reconnect at the same time.
+func backoff(attempt uint, base, max time.Duration) time.Duration {
+	if base <= 0 {
+		base = time.Second
+	}
+
+	delay := base
+	for i := uint(1); i < attempt && (max <= 0 || delay < max) && delay <= math.MaxInt64/2; i++ {
+		delay *= 2
+	}
+
+	if max > 0 && delay > max {
+		delay = max
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func closeConn(conn *websocket.Conn, closef CloseFunc) {
+	if conn == nil {
+		return
+	}
+
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	if err := writeAutoControl(conn, websocket.CloseMessage, msg); err != nil {
+		logger.Debug().Err(err).Msg("error while sending close message")
+	}
+
+	closef()
+}
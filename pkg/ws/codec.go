@@ -0,0 +1,58 @@
+package ws
+
+import (
+	"encoding/hex"
+
+	"github.com/akshaykhairmode/wscli/pkg/codec"
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/logger"
+)
+
+var (
+	binaryDecoder codec.Decoder
+	binaryEncoder codec.Encoder
+)
+
+// SetDecoder sets the decoder used to print received binary messages, nil prints them as hex.
+// It must be called before connecting.
+func SetDecoder(d codec.Decoder) {
+	binaryDecoder = d
+}
+
+// SetEncoder sets the encoder used to convert sent text messages to binary messages, nil sends them as is.
+// It must be called before connecting.
+func SetEncoder(e codec.Encoder) {
+	binaryEncoder = e
+}
+
+// decodeBinary returns the line to print for a binary message, the inflated or decoded payload
+// and false if nothing should be printed. The message is gzip inflated first if --gzipr is set,
+// a message which cannot be decoded is printed as hex.
+func decodeBinary(message []byte) (string, []byte, bool) {
+	if config.Flags.IsGzipResponse {
+		gzBytes, err := unzipGzipBytes(message)
+		if err != nil {
+			logger.Err(err).Msg("error while unzipping bytes")
+			return "", nil, false
+		}
+
+		message = []byte(gzBytes)
+		if binaryDecoder == nil {
+			msg, ok := applyFilter(message)
+			return marked(inMarker, string(msg)), message, ok
+		}
+	}
+
+	if binaryDecoder == nil {
+		return marked(inMarker, hex.EncodeToString(message)), nil, true
+	}
+
+	decoded, err := binaryDecoder.Decode(message)
+	if err != nil {
+		logger.Debug().Err(err).Msg("error while decoding binary message, printing as hex")
+		return marked(inMarker, hex.EncodeToString(message)), nil, true
+	}
+
+	msg, ok := applyFilter(decoded)
+	return formatMessage(msg), decoded, ok
+}
@@ -0,0 +1,161 @@
+package ws
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/filter"
+	"github.com/gorilla/websocket"
+)
+
+var correlateField atomic.Pointer[filter.Filter]
+
+// SetCorrelate sets the --correlate field, a path like id or meta.requestId. An empty field
+// disables the correlation.
+func SetCorrelate(field string) error {
+	if field == "" {
+		correlateField.Store(nil)
+		return nil
+	}
+
+	f, err := filter.New("." + field)
+	if err != nil {
+		return err
+	}
+
+	correlateField.Store(f)
+	return nil
+}
+
+type pendingRequest struct {
+	sentAt time.Time
+	timer  *time.Timer
+}
+
+// track remembers a message about to be sent by its --correlate field, if no reply with the same
+// field value is received within --correlate-timeout a warning is printed. It is called before the
+// write so that a fast reply finds the message, the returned function forgets it if the write failed.
+func (c *Client) track(mt int, message []byte) (untrack func()) {
+	field := correlateField.Load()
+	if field == nil || mt != websocket.TextMessage {
+		return func() {}
+	}
+
+	id, ok := correlationID(field, message)
+	if !ok {
+		return func() {}
+	}
+
+	timeout := config.Flags.Correlate.Timeout
+
+	c.pendingMux.Lock()
+	defer c.pendingMux.Unlock()
+
+	if c.pending == nil {
+		c.pending = map[string]*pendingRequest{}
+	}
+
+	if old, ok := c.pending[id]; ok {
+		c.stopTimer(old)
+	}
+
+	req := &pendingRequest{sentAt: time.Now()}
+	c.timers.Add(1)
+	req.timer = time.AfterFunc(timeout, func() {
+		defer c.timers.Done()
+
+		c.pendingMux.Lock()
+		current := c.pending[id] == req
+		if current {
+			delete(c.pending, id)
+		}
+		c.pendingMux.Unlock()
+
+		if current {
+			c.println(RedColor("no response for %s %s within %s", field, id, timeout))
+		}
+	})
+
+	c.pending[id] = req
+
+	return func() {
+		c.pendingMux.Lock()
+		defer c.pendingMux.Unlock()
+
+		if c.pending[id] == req {
+			c.stopTimer(req)
+			delete(c.pending, id)
+		}
+	}
+}
+
+// correlate returns the round trip annotation if message is the reply to a tracked message.
+func (c *Client) correlate(message []byte) string {
+	field := correlateField.Load()
+	if field == nil {
+		return ""
+	}
+
+	id, ok := correlationID(field, message)
+	if !ok {
+		return ""
+	}
+
+	c.pendingMux.Lock()
+	req, ok := c.pending[id]
+	if ok {
+		c.stopTimer(req)
+		delete(c.pending, id)
+	}
+	c.pendingMux.Unlock()
+
+	if !ok {
+		return ""
+	}
+
+	return " " + BlueColor("(rtt %s)", formatRTT(time.Since(req.sentAt)))
+}
+
+// stopPending stops the timers of the tracked messages and waits for the ones already firing.
+func (c *Client) stopPending() {
+	c.pendingMux.Lock()
+	for id, req := range c.pending {
+		c.stopTimer(req)
+		delete(c.pending, id)
+	}
+	c.pendingMux.Unlock()
+
+	c.timers.Wait()
+}
+
+// stopTimer stops the timer of req, the timers counter is decremented by the timer itself if it fired.
+func (c *Client) stopTimer(req *pendingRequest) {
+	if req.timer.Stop() {
+		c.timers.Done()
+	}
+}
+
+// correlationID returns the JSON encoded value of the field in message.
+// Values are compared JSON encoded so 1 and "1" are different ids.
+func correlationID(field *filter.Filter, message []byte) (string, bool) {
+	values, err := field.Values(message)
+	if err != nil || len(values) != 1 || values[0] == nil {
+		return "", false
+	}
+
+	id, err := json.Marshal(values[0])
+	if err != nil {
+		return "", false
+	}
+
+	return string(id), true
+}
+
+func formatRTT(d time.Duration) string {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond).String()
+	}
+	return d.Round(10 * time.Microsecond).String()
+}
@@ -0,0 +1,35 @@
+package ws
+
+import (
+	"sync/atomic"
+
+	"github.com/akshaykhairmode/wscli/pkg/filter"
+)
+
+var messageFilter atomic.Pointer[filter.Filter]
+
+// SetFilter replaces the filter applied to received messages, an empty expression removes it.
+func SetFilter(expr string) error {
+	if expr == "" {
+		messageFilter.Store(nil)
+		return nil
+	}
+
+	f, err := filter.New(expr)
+	if err != nil {
+		return err
+	}
+
+	messageFilter.Store(f)
+	return nil
+}
+
+// applyFilter returns the message to print and false if the message is filtered out.
+func applyFilter(message []byte) ([]byte, bool) {
+	f := messageFilter.Load()
+	if f == nil {
+		return message, true
+	}
+
+	return f.Apply(message)
+}
@@ -0,0 +1,84 @@
+package ws
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/gorilla/websocket"
+)
+
+// Protocol frames an application protocol on top of the messages of a Client, see the protocol package.
+type Protocol interface {
+	// Prepare returns the url to dial and the additional subprotocols to request for connectURL.
+	// It is called before every connect and reconnect.
+	Prepare(connectURL string) (string, []string, error)
+
+	// Connected is called after every connect and reconnect, before any message is sent.
+	Connected() error
+
+	// Incoming converts a received message to the messages to print and reports whether the
+	// message belongs to the protocol. Messages of the protocol which should not be printed,
+	// like heartbeats, are returned as an empty list.
+	Incoming(mt int, message []byte) ([][]byte, bool)
+}
+
+// SetProtocol sets the protocol of the client, it must be called before connecting.
+func (c *Client) SetProtocol(p Protocol) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.protocol = p
+}
+
+func (c *Client) Protocol() Protocol {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.protocol
+}
+
+// Send writes message as is, without the --binary or --encode conversion and without echo.
+// Protocols use it for their handshake and heartbeat messages.
+func (c *Client) Send(mt int, message []byte) error {
+	conn := c.Conn()
+	if conn == nil {
+		return fmt.Errorf("connection is nil")
+	}
+
+	c.writeMux.Lock()
+	defer c.writeMux.Unlock()
+
+	return WriteMessage(conn, mt, message)
+}
+
+func (c *Client) dial(connectURL string) (*websocket.Conn, CloseFunc, error) {
+	p := c.Protocol()
+	if p == nil {
+		return Connect(connectURL)
+	}
+
+	dialURL, subprotocols, err := p.Prepare(connectURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return Connect(dialURL, subprotocols...)
+}
+
+func (c *Client) connected() {
+	p := c.Protocol()
+	if p == nil {
+		return
+	}
+
+	if err := p.Connected(); err != nil {
+		log.Println(RedColor("protocol error : %s", err))
+	}
+}
+
+func (c *Client) intercept(mt int, message []byte) ([][]byte, bool) {
+	p := c.Protocol()
+	if p == nil {
+		return nil, false
+	}
+
+	return p.Incoming(mt, message)
+}
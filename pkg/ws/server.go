@@ -0,0 +1,134 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/gorilla/websocket"
+)
+
+// Listen accepts WebSocket connections on addr, given as host:port with an optional path
+// (e.g. :8080/ws). Every accepted connection is passed to onAccept as a Client.
+// The returned function stops listening and closes the accepted connections.
+func Listen(addr string, onAccept func(*Client)) (CloseFunc, error) {
+	upgrader := websocket.Upgrader{
+		Subprotocols:      slices.Clone(config.Flags.SubProtocol),
+		EnableCompression: config.Flags.Compress,
+		CheckOrigin:       func(*http.Request) bool { return true },
+	}
+
+	return Serve(addr, func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			logger.Debug().Err(err).Msg("upgrade error")
+			return
+		}
+
+		if config.Flags.ShouldShowResponseHeaders {
+			for k, v := range r.Header {
+				log.Println(k, v)
+			}
+		}
+
+		//the callbacks of the client are registered by onAccept before reading.
+		c := accept(conn, r)
+		onAccept(c)
+
+		c.reading.Add(1)
+		defer c.reading.Done()
+		c.read(conn)
+	})
+}
+
+// Serve serves handler on addr, given as host:port with an optional path like Listen.
+// The returned function stops listening, cancels the context of the running handlers, which
+// should close their connections when it is done, and waits for them to return.
+func Serve(addr string, handler http.HandlerFunc) (CloseFunc, error) {
+	hostPort, path := splitListenAddr(addr)
+
+	ln, err := net.Listen("tcp", hostPort)
+	if err != nil {
+		return nil, fmt.Errorf("listen error : %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var (
+		activeMux sync.Mutex
+		active    sync.WaitGroup
+		closed    bool
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		activeMux.Lock()
+		if closed {
+			activeMux.Unlock()
+			http.Error(w, "server closed", http.StatusServiceUnavailable)
+			return
+		}
+		active.Add(1)
+		activeMux.Unlock()
+
+		defer active.Done()
+		handler(w, r)
+	})
+
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Println(RedColor("listen error : %s", err))
+		}
+	}()
+
+	return func() {
+		if err := srv.Close(); err != nil {
+			logger.Debug().Err(err).Msg("error while closing the listener")
+		}
+
+		activeMux.Lock()
+		closed = true
+		activeMux.Unlock()
+
+		cancel()
+		active.Wait()
+	}, nil
+}
+
+// accept wraps a connection accepted by Listen. The client is not redialed when the connection drops.
+func accept(conn *websocket.Conn, r *http.Request) *Client {
+	c := &Client{
+		conn:     conn,
+		url:      r.RemoteAddr + r.URL.RequestURI(),
+		accepted: true,
+		closef:   KeepAlive(r.Context(), conn),
+	}
+
+	c.resetStamp()
+
+	return c
+}
+
+// splitListenAddr splits :8080/ws into :8080 and /ws, the path is / if there is none.
+func splitListenAddr(addr string) (string, string) {
+	hostPort, path, ok := strings.Cut(addr, "/")
+	if !ok {
+		return hostPort, "/"
+	}
+	return hostPort, "/" + path
+}
@@ -0,0 +1,74 @@
+package ws
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	wireBytesRead    atomic.Int64
+	wireBytesWritten atomic.Int64
+	compressedConns  atomic.Int64
+)
+
+// WireStats returns the bytes read and written on the network by all connections.
+// It includes the handshake, frame headers, compression and TLS overhead.
+func WireStats() (read, written int64) {
+	return wireBytesRead.Load(), wireBytesWritten.Load()
+}
+
+// CompressedConns returns the number of connections for which the server accepted permessage-deflate.
+func CompressedConns() int64 {
+	return compressedConns.Load()
+}
+
+func compressionAccepted(resp *http.Response) bool {
+	if resp == nil {
+		return false
+	}
+
+	for _, ext := range resp.Header.Values("Sec-WebSocket-Extensions") {
+		if strings.Contains(ext, "permessage-deflate") {
+			return true
+		}
+	}
+
+	return false
+}
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// countingDial wraps dial so that the bytes of the returned connections are added to WireStats.
+func countingDial(dial dialFunc) dialFunc {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		return &countingConn{Conn: conn}, nil
+	}
+}
+
+type countingConn struct {
+	net.Conn
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	wireBytesRead.Add(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	wireBytesWritten.Add(int64(n))
+	return n, err
+}
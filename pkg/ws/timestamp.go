@@ -0,0 +1,75 @@
+package ws
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	inMarker  = "«"
+	outMarker = "»"
+)
+
+const wallTimeFormat = "15:04:05.000"
+
+// println prints a received or sent line, prefixed with the timestamp when --timestamp is set.
+func (c *Client) println(line string) {
+	if name := c.Name(); name != "" {
+		line = BlueColor("[%s]", name) + " " + line
+	}
+
+	if config.Flags.Timestamp == "" {
+		log.Println(line)
+		return
+	}
+
+	now := time.Now()
+
+	c.stampMux.Lock()
+	stamp := formatStamp(config.Flags.Timestamp, now, c.connectedAt, c.lastMessage)
+	c.lastMessage = now
+	c.stampMux.Unlock()
+
+	log.Println(stamp + " " + line)
+}
+
+func (c *Client) resetStamp() {
+	c.stampMux.Lock()
+	defer c.stampMux.Unlock()
+
+	now := time.Now()
+	c.connectedAt, c.lastMessage = now, now
+}
+
+func formatStamp(mode string, now, connectedAt, lastMessage time.Time) string {
+	switch mode {
+	case config.TimestampRelative:
+		return fmt.Sprintf("+%.3fs", now.Sub(connectedAt).Seconds())
+	case config.TimestampDelta:
+		return fmt.Sprintf("Δ%.3fs", now.Sub(lastMessage).Seconds())
+	default:
+		return now.Format(wallTimeFormat)
+	}
+}
+
+// marked adds the direction marker to lines which are printed without one unless --timestamp is set.
+func marked(marker, line string) string {
+	if config.Flags.Timestamp == "" {
+		return line
+	}
+
+	return marker + " " + line
+}
+
+func formatSent(mt int, message []byte) string {
+	if mt == websocket.BinaryMessage && !config.Flags.IsBinary {
+		return hex.EncodeToString(message)
+	}
+
+	return string(message)
+}
@@ -10,6 +10,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
@@ -21,41 +22,104 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/akshaykhairmode/wscli/pkg/config"
-	"github.com/akshaykhairmode/wscli/pkg/global"
 	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/akshaykhairmode/wscli/pkg/record"
 	"github.com/fatih/color"
 	"github.com/gorilla/websocket"
 )
 
 type CloseFunc func()
 
-type ReaderFunc func(*websocket.Conn)
+// Connect dials connectURL, subprotocols are requested in addition to the --sub-protocol ones.
+func Connect(connectURL string, subprotocols ...string) (*websocket.Conn, CloseFunc, error) {
+	return ConnectWithHeader(connectURL, nil, subprotocols...)
+}
 
-func Connect() (*websocket.Conn, CloseFunc, ReaderFunc, error) {
+// ConnectWithHeader is Connect with extra request headers, the --header, --origin and --auth ones take precedence.
+func ConnectWithHeader(connectURL string, header http.Header, subprotocols ...string) (*websocket.Conn, CloseFunc, error) {
 
 	closeFunc := func() {}
-	rFunc := ReaderFunc(func(*websocket.Conn) {})
 
-	if config.Flags.ConnectURL == "" {
-		return nil, closeFunc, rFunc, fmt.Errorf("connect url is empty")
+	if connectURL == "" {
+		return nil, closeFunc, fmt.Errorf("connect url is empty")
 	}
 
-	connectURL := strings.Replace(config.Flags.ConnectURL, "%", "%25", 1)
+	connectURL = strings.Replace(connectURL, "%", "%25", 1)
 
 	u, err := url.Parse(connectURL)
 	if err != nil {
-		return nil, closeFunc, rFunc, fmt.Errorf("error while passing the url : %w", err)
+		return nil, closeFunc, fmt.Errorf("error while passing the url : %w", err)
+	}
+
+	headers, err := RequestHeaders()
+	if err != nil {
+		return nil, closeFunc, err
+	}
+
+	for k, v := range header {
+		if _, ok := headers[k]; !ok {
+			headers[k] = v
+		}
+	}
+
+	dialer := websocket.Dialer{
+		Subprotocols:      append(slices.Clone(config.Flags.SubProtocol), subprotocols...),
+		TLSClientConfig:   GetTLSConfig(),
+		EnableCompression: config.Flags.Compress,
+	}
+
+	if dialer.Proxy, err = proxy(); err != nil {
+		return nil, closeFunc, err
+	}
+
+	if dialer.NetDialContext, err = netDial(); err != nil {
+		return nil, closeFunc, err
 	}
 
+	dialer.NetDialContext = countingDial(dialer.NetDialContext)
+
+	c, resp, err := dialer.Dial(u.String(), headers)
+	if err != nil {
+		return nil, closeFunc, fmt.Errorf("dial error : %w", err)
+	}
+
+	if config.Flags.ShouldShowResponseHeaders {
+		for k, v := range resp.Header {
+			log.Println(k, v)
+		}
+	}
+
+	if config.Flags.Compress {
+		accepted := compressionAccepted(resp)
+		if accepted {
+			compressedConns.Add(1)
+		}
+
+		//perf mode shows the count of compressed connections instead.
+		if !config.Flags.IsPerf {
+			if accepted {
+				log.Println(GreenColor("permessage-deflate accepted by the server"))
+			} else {
+				log.Println(RedColor("permessage-deflate not accepted by the server, messages are not compressed"))
+			}
+		}
+	}
+
+	return c, KeepAlive(context.Background(), c), nil
+}
+
+// RequestHeaders returns the headers set with --header, --origin and --auth.
+func RequestHeaders() (http.Header, error) {
 	headers := http.Header{}
 	for _, h := range config.Flags.Headers {
 		headSpl := strings.Split(h, ":")
 		if len(headSpl) != 2 {
-			return nil, closeFunc, rFunc, fmt.Errorf("invalid header : %s", h)
+			return nil, fmt.Errorf("invalid header : %s", h)
 		}
 		headers.Set(headSpl[0], headSpl[1])
 	}
@@ -68,88 +132,133 @@ func Connect() (*websocket.Conn, CloseFunc, ReaderFunc, error) {
 		headers.Set("Authorization", BasicAuth(config.Flags.Auth))
 	}
 
-	dialer := websocket.Dialer{
-		Subprotocols:    config.Flags.SubProtocol,
-		TLSClientConfig: GetTLSConfig(),
+	return headers, nil
+}
+
+// HTTPClient returns a client using the same TLS, proxy and network settings as the WebSocket
+// connections, for protocols which make HTTP requests before connecting.
+func HTTPClient() (*http.Client, error) {
+	proxyFunc, err := proxy()
+	if err != nil {
+		return nil, err
 	}
 
-	if config.Flags.Proxy != "" {
-		proxyURLParsed, err := url.Parse(config.Flags.Proxy)
-		if err != nil {
-			return nil, closeFunc, rFunc, fmt.Errorf("error while parsing the proxy url : %w", err)
-		}
-		dialer.Proxy = http.ProxyURL(proxyURLParsed)
+	dial, err := netDial()
+	if err != nil {
+		return nil, err
 	}
 
-	if config.Flags.UnixSocket != "" {
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			Proxy:           proxyFunc,
+			DialContext:     dial,
+			TLSClientConfig: GetTLSConfig(),
+		},
+	}, nil
+}
 
-		netDialer := &net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}
+func proxy() (func(*http.Request) (*url.URL, error), error) {
+	if config.Flags.Proxy == "" {
+		return nil, nil
+	}
 
-		dialer.NetDialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
-			return netDialer.DialContext(ctx, "unix", config.Flags.UnixSocket)
-		}
+	proxyURLParsed, err := url.Parse(config.Flags.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("error while parsing the proxy url : %w", err)
+	}
 
-	} else if config.Flags.BindAddress != "" || config.Flags.IPVersion != "" {
-		network := "tcp"
-		switch config.Flags.IPVersion {
-		case "4":
-			network = "tcp4"
-		case "6":
-			network = "tcp6"
-		case "":
-		default:
-			return nil, closeFunc, rFunc, fmt.Errorf("invalid ip-version: %s. Use 4 or 6", config.Flags.IPVersion)
-		}
+	return http.ProxyURL(proxyURLParsed), nil
+}
+
+// netDial returns the dial function for --unix-socket, --bind-address and --ip-version, nil if none is set.
+func netDial() (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+
+	if config.Flags.UnixSocket != "" {
 
 		netDialer := &net.Dialer{
 			Timeout:   30 * time.Second,
 			KeepAlive: 30 * time.Second,
 		}
 
-		if config.Flags.BindAddress != "" {
-			addrWithPort := net.JoinHostPort(config.Flags.BindAddress, "0")
-			localAddr, err := net.ResolveTCPAddr(network, addrWithPort)
-			if err != nil {
-				return nil, closeFunc, rFunc, fmt.Errorf("error resolving bind address: %w", err)
-			}
-			netDialer.LocalAddr = localAddr
-		}
+		return func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return netDialer.DialContext(ctx, "unix", config.Flags.UnixSocket)
+		}, nil
+	}
 
-		dialer.NetDialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
-			return netDialer.DialContext(ctx, network, addr)
-		}
+	if config.Flags.BindAddress == "" && config.Flags.IPVersion == "" {
+		return nil, nil
 	}
 
-	c, resp, err := dialer.Dial(u.String(), headers)
-	if err != nil {
-		return nil, closeFunc, rFunc, fmt.Errorf("dial error : %w", err)
+	network := "tcp"
+	switch config.Flags.IPVersion {
+	case "4":
+		network = "tcp4"
+	case "6":
+		network = "tcp6"
+	case "":
+	default:
+		return nil, fmt.Errorf("invalid ip-version: %s. Use 4 or 6", config.Flags.IPVersion)
 	}
 
-	if config.Flags.ShouldShowResponseHeaders {
-		for k, v := range resp.Header {
-			log.Println(k, v)
+	netDialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	if config.Flags.BindAddress != "" {
+		addrWithPort := net.JoinHostPort(config.Flags.BindAddress, "0")
+		localAddr, err := net.ResolveTCPAddr(network, addrWithPort)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving bind address: %w", err)
 		}
+		netDialer.LocalAddr = localAddr
 	}
 
-	closeFunc = func() {
-		if err := c.Close(); err != nil {
+	return func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return netDialer.DialContext(ctx, network, addr)
+	}, nil
+}
+
+// KeepAlive runs PingWorker for conn until the returned function is called or ctx is done, e.g. the
+// server of an accepted connection is closed. The connection is then closed, the returned function
+// waits for it.
+func KeepAlive(ctx context.Context, conn *websocket.Conn) CloseFunc {
+	ctx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		PingWorker(ctx, conn)
+		<-ctx.Done()
+
+		if err := conn.Close(); err != nil {
 			logger.Debug().Err(err).Msg("error while closing the connection")
 		}
+	}()
+
+	return func() {
+		cancel()
+		<-stopped
 	}
+}
 
-	go PingWorker(c)
+// PingWorker pings c every --ping-interval until ctx is done or the connection is closed.
+func PingWorker(ctx context.Context, c *websocket.Conn) {
+	ticker := time.NewTicker(config.Flags.PingInterval)
+	defer ticker.Stop()
 
-	return c, closeFunc, readMessages, nil
-}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 
-func PingWorker(c *websocket.Conn) {
-	for range time.Tick(config.Flags.PingInterval) {
-		err := c.WriteControl(websocket.PingMessage, nil, time.Now().Add(3*time.Second))
+		err := writeAutoControl(c, websocket.PingMessage, nil)
 		if err != nil {
-			if err.Error() == "websocket: close sent" {
+			if errors.Is(err, websocket.ErrCloseSent) || errors.Is(err, net.ErrClosed) {
 				return
 			}
 			logger.Debug().Err(err).Msg("error while pinging")
@@ -163,60 +272,90 @@ func BasicAuth(auth string) string {
 
 var BlueColor = color.New(color.FgBlue).SprintfFunc()
 var GreenColor = color.New(color.FgGreen).SprintfFunc()
+var RedColor = color.New(color.FgRed).SprintfFunc()
 
-func readMessages(conn *websocket.Conn) {
+func (c *Client) readMessages(conn *websocket.Conn) error {
 
-	fn := func(what string) func(appData string) error {
+	fn := func(what string, mt int) func(appData string) error {
 		return func(appData string) error {
+			record.Write(record.In, mt, []byte(appData))
 			if config.Flags.ShowPingPong {
-				log.Println(BlueColor("received %s (data: %s)", what, appData))
+				c.println(BlueColor("%s", marked(inMarker, fmt.Sprintf("received %s (data: %s)", what, appData))))
 			}
 			return nil
 		}
 	}
 
-	defer func() {
-		logger.Debug().Msg("enabling global stop application flag")
-		global.Stop()
-	}()
+	showPing := fn("ping", websocket.PingMessage)
+
+	conn.SetPingHandler(func(appData string) error {
+		if err := showPing(appData); err != nil {
+			return err
+		}
 
-	conn.SetPingHandler(fn("ping"))
-	conn.SetPongHandler(fn("pong"))
+		//reply like the default gorilla ping handler does.
+		err := writeAutoControl(conn, websocket.PongMessage, []byte(appData))
+		if err != nil && !errors.Is(err, websocket.ErrCloseSent) && !errors.Is(err, net.ErrClosed) {
+			return err
+		}
+		return nil
+	})
+	conn.SetPongHandler(fn("pong", websocket.PongMessage))
+
+	conn.SetCloseHandler(func(code int, text string) error {
+		record.WriteClose(record.In, code, text)
+
+		//reply like the default gorilla close handler does.
+		if err := writeAutoControl(conn, websocket.CloseMessage, websocket.FormatCloseMessage(code, "")); err != nil {
+			logger.Debug().Err(err).Msg("error while replying to close message")
+		}
+		return nil
+	})
 
 	for {
 		mt, message, err := conn.ReadMessage()
 		if err != nil {
-			if errors.Is(err, net.ErrClosed) {
-				return
-			}
+			return err
+		}
 
-			log.Println(err.Error())
-			return
+		record.Write(record.In, mt, message)
+
+		if mt == websocket.CloseMessage {
+			log.Println("received close message", message)
+			return nil
 		}
 
-		switch mt {
-		case websocket.TextMessage:
-			log.Println(formatMessage(message))
-		case websocket.BinaryMessage:
-			if config.Flags.IsGzipResponse {
-				gzBytes, err := unzipGzipBytes(message)
-				if err != nil {
-					logger.Err(err).Msg("error while unzipping bytes")
-				} else {
-					log.Println(gzBytes)
-				}
-			} else {
-				log.Println(hex.EncodeToString(message))
+		if msgs, ok := c.intercept(mt, message); ok {
+			for _, msg := range msgs {
+				c.handleMessage(websocket.TextMessage, msg)
 			}
-		case websocket.CloseMessage:
-			log.Println("received close message", message)
-			return
+			continue
 		}
 
+		c.handleMessage(mt, message)
 	}
 
 }
 
+// handleMessage notifies the listeners and prints a received text or binary message.
+func (c *Client) handleMessage(mt int, message []byte) {
+	c.notify(mt, message)
+
+	switch mt {
+	case websocket.TextMessage:
+		rtt := c.correlate(message)
+		if msg, ok := applyFilter(message); ok {
+			c.println(formatMessage(msg) + rtt)
+		}
+	case websocket.BinaryMessage:
+		line, payload, ok := decodeBinary(message)
+		rtt := c.correlate(payload)
+		if ok {
+			c.println(line + rtt)
+		}
+	}
+}
+
 func unzipGzipBytes(gzipBytes []byte) (string, error) {
 	reader := bytes.NewReader(gzipBytes)
 	gzipReader, err := gzip.NewReader(reader)
@@ -251,32 +390,90 @@ func formatMessage(message []byte) string {
 		return GreenColor("« %s", message)
 	}
 
-	return GreenColor("%s", jenc)
+	return GreenColor("%s", marked(inMarker, string(jenc)))
 }
 
-func WriteToServer(conn *websocket.Conn, mt int, message []byte) {
+func WriteToServer(conn *websocket.Conn, mt int, message []byte) error {
 
 	if conn == nil {
-		logger.Error().Msg("Connection is nil")
-		return
+		return fmt.Errorf("connection is nil")
 	}
 
-	if !config.Flags.IsBinary {
-		if err := conn.WriteMessage(mt, message); err != nil {
-			logger.Err(err).Msg("write error")
+	if config.Flags.IsBinary {
+		dec, err := hex.DecodeString(string(message))
+		if err != nil {
+			return fmt.Errorf("error while doing decode string : %w", err)
+		}
+		mt, message = websocket.BinaryMessage, dec
+	} else if binaryEncoder != nil && mt == websocket.TextMessage {
+		enc, err := binaryEncoder.Encode(message)
+		if err != nil {
+			return fmt.Errorf("error while encoding message : %w", err)
 		}
-		return
+		mt, message = websocket.BinaryMessage, enc
 	}
 
-	dec, err := hex.DecodeString(string(message))
-	if err != nil {
-		logger.Err(err).Msg("error while doing decode string")
-		return
+	if err := WriteMessage(conn, mt, message); err != nil {
+		return fmt.Errorf("write error : %w", err)
+	}
+
+	return nil
+}
+
+// WriteMessage sends a text or binary frame as is and records it.
+func WriteMessage(conn *websocket.Conn, mt int, message []byte) error {
+	if conn == nil {
+		return fmt.Errorf("connection is nil")
+	}
+
+	if err := conn.WriteMessage(mt, message); err != nil {
+		return err
 	}
-	if err := conn.WriteMessage(websocket.BinaryMessage, dec); err != nil {
-		logger.Err(err).Msg("write error")
+
+	record.Write(record.Out, mt, message)
+	return nil
+}
+
+// WriteControl sends a ping, pong or close frame and records it.
+func WriteControl(conn *websocket.Conn, mt int, data []byte) error {
+	if err := conn.WriteControl(mt, data, time.Now().Add(3*time.Second)); err != nil {
+		return err
+	}
+
+	if mt == websocket.CloseMessage {
+		code, reason := parseCloseMessage(data)
+		record.WriteClose(record.Out, code, reason)
+	} else {
+		record.Write(record.Out, mt, data)
+	}
+
+	return nil
+}
+
+// writeAutoControl is WriteControl for the frames the connection sends by itself, they are
+// recorded as auto so that a replay does not send them twice.
+func writeAutoControl(conn *websocket.Conn, mt int, data []byte) error {
+	if err := conn.WriteControl(mt, data, time.Now().Add(3*time.Second)); err != nil {
+		return err
+	}
+
+	if mt == websocket.CloseMessage {
+		code, reason := parseCloseMessage(data)
+		record.WriteCloseAuto(record.Out, code, reason)
+	} else {
+		record.WriteAuto(record.Out, mt, data)
+	}
+
+	return nil
+}
+
+// parseCloseMessage splits a close frame payload into its code and reason.
+func parseCloseMessage(data []byte) (int, string) {
+	if len(data) < 2 {
+		return websocket.CloseNoStatusReceived, ""
 	}
 
+	return int(binary.BigEndian.Uint16(data)), string(data[2:])
 }
 
 func GetTLSConfig() *tls.Config {
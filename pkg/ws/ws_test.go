@@ -5,11 +5,20 @@ import (
 	"compress/gzip"
 	"encoding/base64"
 	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/akshaykhairmode/wscli/pkg/config"
+	"github.com/akshaykhairmode/wscli/pkg/filter"
 	"github.com/akshaykhairmode/wscli/pkg/logger"
+	"github.com/gorilla/websocket"
 )
 
 func TestBasicAuth(t *testing.T) {
@@ -94,3 +103,465 @@ func init() {
 	config.Flags = &config.Flag{}
 	logger.Init(io.Discard, nil)
 }
+
+func TestBackoff(t *testing.T) {
+	base := 100 * time.Millisecond
+	max := time.Second
+
+	cases := []struct {
+		attempt uint
+		want    time.Duration
+	}{
+		{1, base},
+		{2, 2 * base},
+		{3, 4 * base},
+		{4, 8 * base},
+		{5, max},
+		{20, max},
+	}
+
+	for _, c := range cases {
+		for range 20 {
+			got := backoff(c.attempt, base, max)
+			if got < c.want/2 || got > c.want {
+				t.Errorf("backoff(%d) = %s, want between %s and %s", c.attempt, got, c.want/2, c.want)
+			}
+		}
+	}
+}
+
+func TestBackoffNoMax(t *testing.T) {
+	base := 100 * time.Millisecond
+
+	cases := []struct {
+		attempt uint
+		want    time.Duration
+	}{
+		{1, base},
+		{2, 2 * base},
+		{3, 4 * base},
+		{4, 8 * base},
+	}
+
+	for _, c := range cases {
+		for range 20 {
+			got := backoff(c.attempt, base, 0)
+			if got < c.want/2 || got > c.want {
+				t.Errorf("backoff(%d) without max = %s, want between %s and %s", c.attempt, got, c.want/2, c.want)
+			}
+		}
+	}
+
+	if got := backoff(200, base, 0); got <= 0 {
+		t.Errorf("backoff(200) without max = %s, want it not to overflow", got)
+	}
+}
+
+func TestParseCloseMessage(t *testing.T) {
+	code, reason := parseCloseMessage(websocket.FormatCloseMessage(websocket.CloseGoingAway, "bye"))
+	if code != websocket.CloseGoingAway || reason != "bye" {
+		t.Errorf("parseCloseMessage() = %d %q, want %d %q", code, reason, websocket.CloseGoingAway, "bye")
+	}
+
+	code, _ = parseCloseMessage(nil)
+	if code != websocket.CloseNoStatusReceived {
+		t.Errorf("parseCloseMessage(nil) code = %d, want %d", code, websocket.CloseNoStatusReceived)
+	}
+}
+
+func TestFormatStamp(t *testing.T) {
+	connectedAt := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	last := connectedAt.Add(2 * time.Second)
+	now := connectedAt.Add(2500 * time.Millisecond)
+
+	cases := map[string]string{
+		config.TimestampWall:     "10:00:02.500",
+		config.TimestampRelative: "+2.500s",
+		config.TimestampDelta:    "Δ0.500s",
+	}
+	for mode, want := range cases {
+		if got := formatStamp(mode, now, connectedAt, last); got != want {
+			t.Errorf("formatStamp(%q) = %q, want %q", mode, got, want)
+		}
+	}
+}
+
+func TestMarked(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+
+	config.Flags = &config.Flag{}
+	if got := marked(inMarker, "abcd"); got != "abcd" {
+		t.Errorf("marked() without timestamp = %q, want %q", got, "abcd")
+	}
+
+	config.Flags = &config.Flag{Timestamp: config.TimestampWall}
+	if got := marked(inMarker, "abcd"); got != "« abcd" {
+		t.Errorf("marked() with timestamp = %q, want %q", got, "« abcd")
+	}
+}
+
+func TestSetFilter(t *testing.T) {
+	defer SetFilter("")
+
+	if err := SetFilter(".a |"); err == nil {
+		t.Error("SetFilter() with invalid expression should return error")
+	}
+
+	if err := SetFilter(`select(.type == "a")`); err != nil {
+		t.Fatalf("SetFilter() error: %v", err)
+	}
+
+	if _, ok := applyFilter([]byte(`{"type":"b"}`)); ok {
+		t.Error("applyFilter() = true for non matching message, want false")
+	}
+
+	if msg, ok := applyFilter([]byte(`{"type":"a"}`)); !ok || string(msg) != `{"type":"a"}` {
+		t.Errorf("applyFilter() = %q, %v, want message to be shown", msg, ok)
+	}
+
+	SetFilter("")
+	if msg, ok := applyFilter([]byte("plain")); !ok || string(msg) != "plain" {
+		t.Errorf("applyFilter() without filter = %q, %v, want message to be shown", msg, ok)
+	}
+}
+
+func TestCompressionAccepted(t *testing.T) {
+	if compressionAccepted(nil) {
+		t.Error("compressionAccepted(nil) = true, want false")
+	}
+
+	resp := &http.Response{Header: http.Header{}}
+	if compressionAccepted(resp) {
+		t.Error("compressionAccepted() without extension header = true, want false")
+	}
+
+	resp.Header.Set("Sec-WebSocket-Extensions", "permessage-deflate; server_no_context_takeover; client_no_context_takeover")
+	if !compressionAccepted(resp) {
+		t.Error("compressionAccepted() with permessage-deflate = false, want true")
+	}
+}
+
+func TestCountingConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	conn := &countingConn{Conn: client}
+	readBefore, writtenBefore := WireStats()
+
+	go func() {
+		buf := make([]byte, 5)
+		io.ReadFull(server, buf)
+		server.Write([]byte("abc"))
+	}()
+
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("Read() error: %v", err)
+	}
+
+	read, written := WireStats()
+	if read-readBefore != 3 || written-writtenBefore != 5 {
+		t.Errorf("WireStats() delta = %d, %d, want 3, 5", read-readBefore, written-writtenBefore)
+	}
+}
+
+type failingDecoder struct{}
+
+func (failingDecoder) Decode([]byte) ([]byte, error) { return nil, io.ErrUnexpectedEOF }
+
+func TestDecodeBinary(t *testing.T) {
+	origFlags := config.Flags
+	defer func() {
+		config.Flags = origFlags
+		SetDecoder(nil)
+	}()
+
+	config.Flags = &config.Flag{}
+
+	if got, _, _ := decodeBinary([]byte{0xab, 0xcd}); got != "abcd" {
+		t.Errorf("decodeBinary() without decoder = %q, want hex", got)
+	}
+
+	SetDecoder(failingDecoder{})
+	if got, _, _ := decodeBinary([]byte{0xab, 0xcd}); got != "abcd" {
+		t.Errorf("decodeBinary() with failing decoder = %q, want hex fallback", got)
+	}
+}
+
+func TestCorrelationID(t *testing.T) {
+	cases := []struct {
+		field, msg, want string
+		ok               bool
+	}{
+		{"id", `{"id":1}`, "1", true},
+		{"id", `{"id":"1"}`, `"1"`, true},
+		{"id", `{"id":12345678901234567890}`, "12345678901234567890", true},
+		{"meta.requestId", `{"meta":{"requestId":"a"}}`, `"a"`, true},
+		{"meta.requestId", `{"meta":"a"}`, "", false},
+		{"id", `{"id":null}`, "", false},
+		{"id", `{"other":1}`, "", false},
+		{"id", `not json`, "", false},
+	}
+
+	for _, c := range cases {
+		field, err := filter.New("." + c.field)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, ok := correlationID(field, []byte(c.msg))
+		if got != c.want || ok != c.ok {
+			t.Errorf("correlationID(%q, %s) = %q, %v, want %q, %v", c.field, c.msg, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestCorrelate(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+
+	config.Flags = &config.Flag{NoColor: true, Correlate: config.Correlate{Field: "id", Timeout: time.Hour}}
+	if err := SetCorrelate("id"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetCorrelate("")
+
+	c := NewClient()
+	defer c.Close()
+
+	c.track(websocket.TextMessage, []byte(`{"id":7,"op":"get"}`))
+	if got := c.correlate([]byte(`{"id":8}`)); got != "" {
+		t.Errorf("correlate() for unknown id = %q, want empty", got)
+	}
+
+	if got := c.correlate([]byte(`{"id":7,"result":1}`)); !strings.Contains(got, "rtt") {
+		t.Errorf("correlate() for sent id = %q, want rtt annotation", got)
+	}
+
+	if got := c.correlate([]byte(`{"id":7}`)); got != "" {
+		t.Errorf("correlate() for already answered id = %q, want empty", got)
+	}
+
+	//a failed write forgets the message.
+	if err := c.Write(websocket.TextMessage, []byte(`{"id":8}`)); err == nil {
+		t.Fatal("Write() without connection should return error")
+	}
+	if got := c.correlate([]byte(`{"id":8}`)); got != "" {
+		t.Errorf("correlate() for a failed write = %q, want empty", got)
+	}
+
+	config.Flags.Correlate.Timeout = time.Millisecond
+	c.track(websocket.TextMessage, []byte(`{"id":9}`))
+	time.Sleep(50 * time.Millisecond)
+
+	c.pendingMux.Lock()
+	_, pending := c.pending[`9`]
+	c.pendingMux.Unlock()
+	if pending {
+		t.Error("request is still pending after the timeout")
+	}
+}
+
+func TestSplitListenAddr(t *testing.T) {
+	cases := map[string][2]string{
+		":8080":               {":8080", "/"},
+		":8080/ws":            {":8080", "/ws"},
+		"127.0.0.1:9000/a/b/": {"127.0.0.1:9000", "/a/b/"},
+		"localhost:0/":        {"localhost:0", "/"},
+	}
+
+	for addr, want := range cases {
+		if hostPort, path := splitListenAddr(addr); hostPort != want[0] || path != want[1] {
+			t.Errorf("splitListenAddr(%q) = %q, %q, want %q, %q", addr, hostPort, path, want[0], want[1])
+		}
+	}
+}
+
+func TestListen(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true, PingInterval: time.Minute, Reconnect: config.Reconnect{Enabled: true}}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	accepted := make(chan *Client, 1)
+	messages := make(chan string, 1)
+	closed := make(chan struct{})
+
+	closef, err := Listen(addr+"/ws", func(c *Client) {
+		c.OnMessage(func(_ int, message []byte) { messages <- string(message) })
+		c.OnClose(func() { close(closed) })
+		accepted <- c
+	})
+	if err != nil {
+		t.Fatalf("Listen() error: %v", err)
+	}
+	defer closef()
+
+	if _, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/other", nil); err == nil {
+		t.Error("Dial() to another path should fail")
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Dial() error: %v", err)
+	}
+
+	var client *Client
+	select {
+	case client = <-accepted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for the accepted connection")
+	}
+
+	if !strings.HasSuffix(client.URL(), "/ws") {
+		t.Errorf("URL() = %q, want the remote address with the path", client.URL())
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte("from client"))
+	select {
+	case got := <-messages:
+		if got != "from client" {
+			t.Errorf("received %q, want %q", got, "from client")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for the message")
+	}
+
+	if err := client.Write(websocket.TextMessage, []byte("from server")); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	if _, got, err := conn.ReadMessage(); err != nil || string(got) != "from server" {
+		t.Errorf("client read %q, %v", got, err)
+	}
+
+	//the accepted connection is not redialed even with --reconnect.
+	conn.Close()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnClose not called after the connection dropped")
+	}
+}
+
+// lineWriter passes every log line to a channel.
+type lineWriter chan string
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	w <- strings.TrimSpace(string(p))
+	return len(p), nil
+}
+
+func TestWriteControlMarked(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true, PingInterval: time.Minute, ShowPingPong: true, Timestamp: config.TimestampWall}
+
+	lines := make(lineWriter, 10)
+	log.SetOutput(lines)
+	defer log.SetOutput(os.Stderr)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		//the default ping handler answers with a pong.
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient()
+	if err := client.Connect("ws" + strings.TrimPrefix(srv.URL, "http")); err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.WriteControl(websocket.PingMessage, []byte("hi")); err != nil {
+		t.Fatalf("WriteControl() error: %v", err)
+	}
+
+	//the pong may be printed before the ping.
+	var printed []string
+	for range 2 {
+		select {
+		case got := <-lines:
+			printed = append(printed, got)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for the ping and pong lines, got %q", printed)
+		}
+	}
+
+	for _, want := range []string{"» sent ping (data: hi)", "« received pong (data: hi)"} {
+		if !slices.ContainsFunc(printed, func(line string) bool { return strings.HasSuffix(line, want) }) {
+			t.Errorf("printed %q, want a line ending with %q", printed, want)
+		}
+	}
+}
+
+func TestWriteControlCloseNoReconnect(t *testing.T) {
+	origFlags := config.Flags
+	defer func() { config.Flags = origFlags }()
+	config.Flags = &config.Flag{NoColor: true, PingInterval: time.Minute, Reconnect: config.Reconnect{Enabled: true, Delay: 10 * time.Millisecond}}
+	logger.Init(io.Discard, nil)
+
+	dials := make(chan struct{}, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		dials <- struct{}{}
+
+		//the default close handler answers the close frame.
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	closed := make(chan struct{})
+	client := NewClient()
+	client.OnClose(func() { close(closed) })
+	if err := client.Connect("ws" + strings.TrimPrefix(srv.URL, "http")); err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+	defer client.Close()
+	<-dials
+
+	if err := client.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye")); err != nil {
+		t.Fatalf("WriteControl() error: %v", err)
+	}
+
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnClose not called after the close frame was sent")
+	}
+
+	select {
+	case <-dials:
+		t.Error("the connection was redialed after the close frame was sent")
+	case <-time.After(100 * time.Millisecond):
+	}
+}